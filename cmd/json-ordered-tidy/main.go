@@ -1,21 +1,49 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
+	"strings"
 
+	"github.com/ActiveState/json-ordered-tidy/pkg/editorconfig"
 	"github.com/ActiveState/json-ordered-tidy/pkg/jsontidier"
 )
 
 type config struct {
-	Indent    *string
-	KeyOrder  map[string][]string
-	ArraySort []string
+	Indent                *string
+	KeyOrder              map[string][]string
+	ArraySort             []jsontidier.ArraySortRule
+	ArrayKeyOrder         map[string]string
+	MaxLineWidth          int
+	Format                map[string]string
+	EscapeHTML            bool
+	StringEscapes         string
+	LineEnding            string
+	BOM                   string
+	FinalNewline          *bool
+	AlignValues           bool
+	AlignValuesMaxPadding int
+	PreserveBlankLines    bool
+	NormalizeNumbers      []string
+	DuplicateKeys         string
+	EmbeddedJSON          map[string]embeddedConfig
+}
+
+// embeddedConfig is an "embeddedJSON" rule. Apart from "output", it can
+// contain the same keys as the config file, which are used to tidy the
+// embedded documents.
+type embeddedConfig struct {
+	Output string
+	config
 }
 
 type indentFlag struct {
@@ -39,6 +67,17 @@ type program struct {
 	verbose   bool
 	debug     bool
 	indent    indentFlag
+	sortKeys  string
+	canonical bool
+	minify    bool
+	jsonc     bool
+	json5     bool
+	strict    bool
+	commas    bool
+	jsonl     bool
+	stream    bool
+	markdown  bool
+	ecFinder  *editorconfig.Finder
 	config    config
 	extRegexp *regexp.Regexp
 	exit      int
@@ -46,14 +85,45 @@ type program struct {
 
 func main() {
 	var indent indentFlag
-	flag.Var(&indent, "indent", "The string with which to indent JSON. Defaults to 4 spaces.")
+	flag.Var(&indent, "indent", `The string with which to indent JSON. Defaults to 4 spaces. Use "auto" to keep each file's existing indentation.`)
 	var config string
 	flag.StringVar(&config, "config", "", "A config file containing key ordering and array sorting specifications.")
+	var sortKeys string
+	flag.StringVar(&sortKeys, "sort-keys", "", `Sort the keys of every object which does not match a "keyOrder" path. This can be "alphabetical" or "natural".`)
+
+	var canonical bool
+	flag.BoolVar(&canonical, "canonical", false, "Write the output in the JSON Canonicalization Scheme (RFC 8785) instead of pretty printing it.")
+
+	var minify bool
+	flag.BoolVar(&minify, "minify", false, "Write the output on a single line with no whitespace instead of pretty printing it. Keys and arrays are still sorted.")
+
+	var jsonc bool
+	flag.BoolVar(&jsonc, "jsonc", false, `Allow "//" and "/* */" comments in every file and keep them in the output. This is always enabled for files ending in ".jsonc".`)
+
+	var json5 bool
+	flag.BoolVar(&json5, "json5", false, `Parse every file as JSON5. This is always enabled for files ending in ".json5".`)
+
+	var strict bool
+	flag.BoolVar(&strict, "strict", false, "Write JSONC and JSON5 files as strict JSON, dropping any comments.")
+
+	var commas bool
+	flag.BoolVar(&commas, "allow-trailing-commas", false, "Accept trailing commas in arrays and objects and remove them. Each one removed is reported.")
+
+	var jsonl bool
+	flag.BoolVar(&jsonl, "jsonl", false, `Tidy every file as JSON Lines, with one compact document per line. This is always enabled for files ending in ".jsonl" or ".ndjson".`)
+
+	var stream bool
+	flag.BoolVar(&stream, "stream", false, "Accept files containing any number of JSON documents one after another, including JSON text sequences (RFC 7464), and tidy each document.")
+
+	var markdown bool
+	flag.BoolVar(&markdown, "markdown", false, `Tidy the "json" and "jsonc" code blocks in every file as Markdown. This is always enabled for files ending in ".md" or ".markdown".`)
 
 	var stdout bool
 	flag.BoolVar(&stdout, "stdout", false, "Instead of tidying file in place, output content to stdout. When this is flag is set the file contents will be printed even when it is already tidy. This flag is irrelevant when running in -check mode.")
 	var check bool
 	flag.BoolVar(&check, "check", false, "Run in check mode. In this mode we exit 0 if all files are already tidy, otherwise the exit status is 1.")
+	var useEditorConfig bool
+	flag.BoolVar(&useEditorConfig, "editorconfig", false, "Use the indent_style, indent_size and end_of_line settings from any .editorconfig files that apply to each file. Settings in the config file or on the command line take precedence.")
 	var verbose bool
 	flag.BoolVar(&verbose, "verbose", false, "Be more verbose with output.")
 	var debug bool
@@ -80,16 +150,40 @@ func main() {
 		os.Exit(1)
 	}
 
+	if canonical && minify {
+		usage("You passed both -canonical and -minify, which do not make any sense together")
+		os.Exit(1)
+	}
+
+	if sortKeys != "" && sortKeys != "alphabetical" && sortKeys != "natural" {
+		usage(fmt.Sprintf(`The -sort-keys flag must be "alphabetical" or "natural", not %q`, sortKeys))
+		os.Exit(1)
+	}
+
 	p := program{
 		stdout:    stdout,
 		check:     check,
 		verbose:   verbose,
 		indent:    indent,
+		sortKeys:  sortKeys,
+		canonical: canonical,
+		minify:    minify,
+		jsonc:     jsonc,
+		json5:     json5,
+		strict:    strict,
+		commas:    commas,
+		jsonl:     jsonl,
+		stream:    stream,
+		markdown:  markdown,
 		debug:     debug,
 		extRegexp: regexp.MustCompile(regexp.QuoteMeta(ext) + `$`),
 		exit:      0,
 	}
 
+	if useEditorConfig {
+		p.ecFinder = editorconfig.NewFinder()
+	}
+
 	if config != "" {
 		c, err := readConfigFile(config)
 		if err != nil {
@@ -132,9 +226,88 @@ func readConfigFile(path string) (config, error) {
 		return config{}, err
 	}
 
+	err = c.validate()
+	if err != nil {
+		return config{}, err
+	}
 	return c, nil
 }
 
+func (c config) validate() error {
+	for path, mode := range c.ArrayKeyOrder {
+		if mode != "first" && mode != "union" {
+			return fmt.Errorf(`the arrayKeyOrder value for %s must be "first" or "union", not %q`, path, mode)
+		}
+	}
+	for path, format := range c.Format {
+		if format != "inline" && format != "expanded" && format != "compact" {
+			return fmt.Errorf(`the format value for %s must be "inline", "expanded" or "compact", not %q`, path, format)
+		}
+	}
+	switch c.StringEscapes {
+	case "", "minimal", "preserve", "ascii":
+	default:
+		return fmt.Errorf(`stringEscapes must be "minimal", "preserve" or "ascii", not %q`, c.StringEscapes)
+	}
+	switch c.LineEnding {
+	case "", "lf", "crlf", "auto":
+	default:
+		return fmt.Errorf(`lineEnding must be "lf", "crlf" or "auto", not %q`, c.LineEnding)
+	}
+	switch c.BOM {
+	case "", "preserve", "add", "remove":
+	default:
+		return fmt.Errorf(`bom must be "preserve", "add" or "remove", not %q`, c.BOM)
+	}
+	switch c.DuplicateKeys {
+	case "", "error", "keep-first", "keep-last", "keep-all":
+	default:
+		return fmt.Errorf(`duplicateKeys must be "error", "keep-first", "keep-last" or "keep-all", not %q`, c.DuplicateKeys)
+	}
+	for path, e := range c.EmbeddedJSON {
+		if e.Output != "" && e.Output != "compact" && e.Output != "pretty" {
+			return fmt.Errorf(`the embeddedJSON output for %s must be "compact" or "pretty", not %q`, path, e.Output)
+		}
+		if err := e.config.validate(); err != nil {
+			return fmt.Errorf("in the embeddedJSON rule for %s: %s", path, err)
+		}
+	}
+
+	return nil
+}
+
+// newParams returns the NewParams for the settings in the config file.
+func (c config) newParams() jsontidier.NewParams {
+	np := jsontidier.NewParams{
+		Indent:                c.Indent,
+		KeyOrder:              c.KeyOrder,
+		ArraySortRules:        c.ArraySort,
+		ArrayKeyOrder:         c.ArrayKeyOrder,
+		MaxLineWidth:          c.MaxLineWidth,
+		Format:                c.Format,
+		EscapeHTML:            c.EscapeHTML,
+		StringEscapes:         c.StringEscapes,
+		LineEnding:            c.LineEnding,
+		BOM:                   c.BOM,
+		FinalNewline:          c.FinalNewline,
+		AlignValues:           c.AlignValues,
+		AlignValuesMaxPadding: c.AlignValuesMaxPadding,
+		PreserveBlankLines:    c.PreserveBlankLines,
+		NormalizeNumbers:      c.NormalizeNumbers,
+		DuplicateKeys:         c.DuplicateKeys,
+	}
+	if len(c.EmbeddedJSON) > 0 {
+		np.EmbeddedJSON = make(map[string]jsontidier.EmbeddedJSONRule)
+		for path, e := range c.EmbeddedJSON {
+			np.EmbeddedJSON[path] = jsontidier.EmbeddedJSONRule{
+				Output: e.Output,
+				Params: e.config.newParams(),
+			}
+		}
+	}
+	return np
+}
+
 func usage(err string) {
 	if err != "" {
 		fmt.Printf("\n  *** %s ***\n", err)
@@ -146,16 +319,183 @@ func usage(err string) {
 
   This command tidies all the files it finds, preserving the existing map key
   order. If given a directory it looks for files matching the given -ext
-  value.
+  value. Only files matching the -ext value or ending in ".jsonc", ".json5",
+  ".jsonl" or ".ndjson" are tidied, including files named on the command
+  line.
 
   This tidier is capable of sorting object keys in arbitrary orders, as well
   as optional sorting the contents of arrays. You can configure this using a
   JSON-based config file.
 
-  The config file should be a JSON object. It can contain three keys,
-  "indent", "keyOrder" and "arraySort". You can specify just one key as
-  well. Note that specifying "indent" in the config file will override any
-  command line.
+  A file can contain any JSON value, not just an object. If a file contains
+  an array then the path "$" matches that array and "$[*]" matches each of
+  its elements.
+
+  Files ending in ".jsonc", or every file if you pass the -jsonc flag, can
+  contain "//" and "/* */" comments. Each comment stays attached to the
+  object member or array element next to it when keys are reordered or
+  arrays are sorted. A comment on the same line as a member is written after
+  it, and other comments are written on their own lines before it. Arrays and
+  objects containing comments are always written with one element per line.
+  Comments are dropped by -canonical and -minify.
+
+  Files ending in ".json5", or every file if you pass the -json5 flag, are
+  parsed as JSON5. These can contain comments, unquoted keys, single quoted
+  strings, trailing commas, hexadecimal numbers, Infinity and NaN. The
+  "keyOrder" and "arraySort" rules apply as usual, and keys, strings and
+  numbers are written exactly as they were, so the output is still JSON5.
+  Trailing commas are removed.
+
+  If you pass the -strict flag then JSONC and JSON5 files are written as
+  strict JSON instead. Comments are dropped, and keys, strings and numbers
+  are converted to JSON. A file containing Infinity or NaN cannot be
+  converted.
+
+  If you pass the -allow-trailing-commas flag then a trailing comma after the
+  last element of an array or object is accepted and removed from the
+  output. Each one is reported with its file and line, like
+  "file.json:12: trailing comma in $['list']".
+
+  Files ending in ".jsonl" or ".ndjson", or every file if you pass the -jsonl
+  flag, are tidied as JSON Lines. Each line is its own document, so "$"
+  matches the root of each line, and each document is written back on a
+  single line with no whitespace. These files are read and written one line
+  at a time, and errors include the line number.
+
+  If you pass the -stream flag then a file can contain any number of JSON
+  documents one after another. Every document is tidied, and each one after
+  the first starts on a new line. This also accepts JSON text sequences from
+  RFC 7464, where each document starts with a record separator (0x1E). These
+  separators are kept before the document they came before.
+
+  Files ending in ".md" or ".markdown", or every file if you pass the
+  -markdown flag, are tidied as Markdown. Every fenced code block marked as
+  "json" or "jsonc" is tidied, and everything else in the file is left
+  exactly as it was. Comments are allowed in "jsonc" blocks. A block which
+  cannot be parsed is left as it was and reported along with the line it
+  starts on, and the exit status is 1. Markdown files are only tidied if
+  they match the -ext value, so pass "-ext .md" to tidy them.
+
+  If you pass the -editorconfig flag then the indent_style, indent_size and
+  end_of_line settings from any .editorconfig files that apply to each file
+  are used. Settings in the config file or on the command line take
+  precedence over these. If you set the indent to "auto" then each file keeps
+  its existing indentation.
+
+  The config file should be a JSON object. It can contain the keys "indent",
+  "maxLineWidth", "format", "alignValues", "alignValuesMaxPadding",
+  "preserveBlankLines", "escapeHTML", "stringEscapes", "normalizeNumbers",
+  "lineEnding", "bom", "finalNewline", "duplicateKeys", "embeddedJSON",
+  "keyOrder", "arraySort" and "arrayKeyOrder". You can specify just one key
+  as well. Note that specifying "indent" in the config file will override
+  any command line.
+
+  By default every non-empty array and object is written with one element per
+  line. If you set "maxLineWidth" to a number greater than zero then any array
+  or object which fits on a single line without making that line longer than
+  this many characters is written on one line, like "enum": ["a", "b", "c"].
+
+  The "format" key should contain an object where the keys are JSON Path
+  expressions and the values are "inline", "expanded" or "compact". A
+  matching array or object, along with everything inside it, is written on
+  one line ("inline"), on one line without any spaces ("compact"), or with
+  one element per line ("expanded"), regardless of "maxLineWidth". A path
+  nested inside an "expanded" path can have its own format.
+
+    "format": {
+        "$..enum": "inline",
+        "$..coordinates": "compact",
+        "$.data": "expanded"
+    }
+
+  If you set "alignValues" to true then the values of any object which only
+  contains scalar values are lined up in a column:
+
+    "name":    "x",
+    "version": "1"
+
+  Set "alignValuesMaxPadding" to a number greater than zero to skip this for
+  any object where a key would need more than that many spaces of padding.
+
+  If you set "preserveBlankLines" to true then a blank line between two
+  members of an object is kept, and it stays with the member that followed
+  it when the keys are sorted. A blank line is never written before the
+  first member of an object. An object containing blank lines is not
+  written on a single line unless its path has an "inline" or "compact"
+  format.
+
+  Numbers are written exactly as they appear in the original document. The
+  "normalizeNumbers" key should contain an array of JSON Path expressions.
+  Every number at or inside a matching path is rewritten in its shortest form
+  without losing any precision, so "1.50E+2" becomes "150", "-0" becomes "0"
+  and "1.0" becomes "1". An exponent is only used if the original number
+  had one, and then only for numbers of 1e21 or more, or less than 1e-6. Use
+  "$" to normalize every number.
+
+    "normalizeNumbers": ["$.data", "$..price"]
+
+  Strings are written with only the escapes that JSON requires. If you set
+  "escapeHTML" to true then "<", ">" and "&" are also escaped as "\u003c",
+  "\u003e" and "\u0026".
+
+  You can change how strings are escaped by setting "stringEscapes" to one
+  of:
+
+  minimal  - Only use the escapes that JSON requires. Everything else is
+             written as raw UTF-8. This is the default.
+
+  preserve - Write keys and strings exactly as they were in the original
+             file, including escapes like "\u00e9" or "\/".
+
+  ascii    - Escape every non-ASCII character, so the output is 7-bit
+             clean.
+
+  The "lineEnding" key can be "lf", "crlf" or "auto". With "auto", which is
+  the default, each file keeps the line ending used by its first line. The
+  "bom" key can be "preserve", "add" or "remove". With "preserve", which is
+  the default, files which start with a UTF-8 byte order mark keep it. Set
+  "finalNewline" to false if you don't want a line ending at the end of each
+  file.
+
+  A file where a key appears more than once in the same object is an error
+  by default. You can set "duplicateKeys" to one of:
+
+  error      - Refuse to tidy the file. This is the default.
+
+  keep-first - Keep only the first value for the key.
+
+  keep-last  - Keep only the last value for the key, in the position where
+               the key first appeared.
+
+  keep-all   - Keep every value. They are written one after another
+               wherever the key is sorted to.
+
+  Each duplicate is reported with its file and line, like
+  "file.json:12: duplicate key "name" in $['name']".
+
+  Some files store JSON documents in strings, like
+  "policy": "{\"Version\": \"2012-10-17\"}". The "embeddedJSON" key should
+  contain an object where the keys are JSON Path expressions for these
+  strings and the values are rules for tidying them. Each rule can contain
+  the same keys as the config file, where "$" is the root of the embedded
+  document, along with an "output" key. With "compact", which is the
+  default, the tidied document is written back into the string on one line
+  with no whitespace. With "pretty" it is written the way the rule's
+  "indent", "maxLineWidth" and "format" say. Command line flags like -sort-keys do not
+  apply to embedded documents. A file is not tidied if a matching string
+  does not contain valid JSON.
+
+    "embeddedJSON": {
+        "$..policy": {
+            "output": "pretty",
+            "format": {
+                "$.Statement[*].Action": "inline"
+            },
+            "keyOrder": {
+                "$": ["Version", "Statement"]
+            }
+        }
+    }
 
   The "keyOrder" key should in turn contain an object where the keys are JSON
   Path expressions and the values are arrays of key names. The JSON Path
@@ -211,14 +551,71 @@ func usage(err string) {
   If you want to sort all of an object's keys in case-insensitive alphanumeric
   order you can provide an empty array for the key order.
 
+  If you want to sort the keys of every object you can pass the -sort-keys
+  flag instead of writing a config file. With "-sort-keys alphabetical" keys
+  are sorted in case-insensitive alphanumeric order. With "-sort-keys
+  natural" runs of digits are compared numerically, so "item2" sorts before
+  "item10". Objects matching a "keyOrder" path are still sorted as specified
+  by the config file.
+
   The "arraySort" key is an array of JSON Path expressions. Any array matching
   the expression will be sorted numerically or as strings, as
   appropriate. Strings are sorted in in case-insensitive alphanumeric order.
 
+  If you pass the -canonical flag the output is written in the JSON
+  Canonicalization Scheme (JCS) from RFC 8785, which is suitable for signing
+  or hashing. In this mode the keys of every object are sorted by their
+  UTF-16 code units, regardless of any "keyOrder" config, numbers are written
+  the way JavaScript writes them, and there is no whitespace at all, not even
+  a final newline. Arrays are still sorted according to the "arraySort"
+  config.
+
+  If you pass the -minify flag the output is written on a single line with no
+  whitespace, followed by a newline. Keys and arrays are still sorted
+  according to your config, but "indent", "maxLineWidth" and "format" are
+  ignored.
+
+  An entry in "arraySort" can also be an object with "path", "comparator",
+  "order" and "stable" keys. Setting the comparator to "semver" sorts strings
+  by semantic version precedence, so "1.9.3-beta" comes before "1.9.3", which
+  comes before "1.10.0". Strings that are not valid semantic versions are
+  sorted after all the valid versions.
+
+  The "deep" comparator sorts arrays containing any type of value, which is
+  useful for arrays of arrays like coordinate pairs. Values of different types
+  are ordered null, false, true, numbers, strings, arrays and then
+  objects. Numbers are compared numerically and strings are compared
+  case-insensitively, with ties broken by a case-sensitive comparison. Arrays
+  are compared element by element, so [[2,1],[1,5],[1,2]] becomes
+  [[1,2],[1,5],[2,1]], and an array sorts before any longer array it is a
+  prefix of. Objects are compared the same way, member by member in their
+  tidied key order, comparing each key and then its value.
+
+  Setting "order" to "desc" sorts in descending order. By default this is the
+  exact reverse of the ascending order, so elements which compare as equal
+  end up in the reverse of their original order. Set "stable" to "original"
+  to keep equal elements in their original order.
+
+    "arraySort": [
+        "$..required",
+        {"path": "$.supportedVersions", "comparator": "semver", "order": "desc"}
+    ]
+
+  The "arrayKeyOrder" key should contain an object where the keys are JSON
+  Path expressions for arrays and the values are either "first" or
+  "union". Every object in a matching array has its keys reordered to match
+  the first object in the array ("first"), or the order in which the keys are
+  first seen across all of the array's objects ("union"). Keys which aren't in
+  that order go last. Objects which match a "keyOrder" path are left alone.
+
 `)
 	flag.PrintDefaults()
 }
 
+// jsonExtRegexp matches the extensions of JSON files with their own parsing
+// mode. These are tidied along with the files matching -ext.
+var jsonExtRegexp = regexp.MustCompile(`\.(?:jsonc|json5|jsonl|ndjson)$`)
+
 func (p *program) handlePath(path string) {
 	fi, err := os.Stat(path)
 	if err != nil {
@@ -228,7 +625,7 @@ func (p *program) handlePath(path string) {
 	}
 	if fi.IsDir() {
 		p.recurseDir(path)
-	} else if p.extRegexp.MatchString(path) {
+	} else if p.extRegexp.MatchString(path) || jsonExtRegexp.MatchString(path) {
 		p.tidy(fi, path)
 	}
 }
@@ -257,7 +654,80 @@ func (p *program) recurseDir(dir string) {
 	}
 }
 
+// applyEditorConfig sets the indent and line ending from the .editorconfig
+// files for the given file, unless these were already set by the config file
+// or command line.
+func (p *program) applyEditorConfig(np *jsontidier.NewParams, file string) error {
+	props, err := p.ecFinder.Properties(file)
+	if err != nil {
+		return err
+	}
+
+	if np.Indent == nil {
+		size := props["indent_size"]
+		if size == "tab" {
+			size = props["tab_width"]
+		}
+		n, err := strconv.Atoi(size)
+		if err != nil || n <= 0 {
+			n = 4
+		}
+
+		switch props["indent_style"] {
+		case "tab":
+			indent := "\t"
+			np.Indent = &indent
+		case "space":
+			indent := strings.Repeat(" ", n)
+			np.Indent = &indent
+		default:
+			if size != "" {
+				indent := strings.Repeat(" ", n)
+				np.Indent = &indent
+			}
+		}
+	}
+
+	if np.LineEnding == "" {
+		switch props["end_of_line"] {
+		case "lf", "crlf":
+			np.LineEnding = props["end_of_line"]
+		}
+	}
+
+	return nil
+}
+
 func (p *program) tidy(fi os.FileInfo, file string) {
+	np := p.config.newParams()
+	np.SortKeys = p.sortKeys
+	np.Canonical = p.canonical
+	np.Minify = p.minify
+	np.JSONC = p.jsonc || filepath.Ext(file) == ".jsonc"
+	np.JSON5 = p.json5 || filepath.Ext(file) == ".json5"
+	np.StrictJSON = p.strict
+	np.AllowTrailingCommas = p.commas
+	np.Stream = p.stream
+	np.Debug = p.debug
+	if np.Indent == nil && p.indent.set {
+		np.Indent = &p.indent.value
+	}
+	if p.ecFinder != nil {
+		err := p.applyEditorConfig(&np, file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not read the .editorconfig settings for %s: %s\n", file, err)
+			p.exit = 1
+			return
+		}
+	}
+	jt := jsontidier.NewJSONTidier(np)
+
+	ext := filepath.Ext(file)
+	if p.jsonl || ext == ".jsonl" || ext == ".ndjson" {
+		p.tidyLines(jt, fi, file)
+		return
+	}
+
 	orig, err := ioutil.ReadFile(file)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Could not read file %s: %s\n", file, err)
@@ -265,24 +735,25 @@ func (p *program) tidy(fi os.FileInfo, file string) {
 		return
 	}
 
-	np := jsontidier.NewParams{
-		KeyOrder:  p.config.KeyOrder,
-		ArraySort: p.config.ArraySort,
-		Debug:     p.debug,
-	}
-	if p.config.Indent != nil {
-		np.Indent = p.config.Indent
-	} else if p.indent.set {
-		np.Indent = &p.indent.value
+	var tidied []byte
+	if p.markdown || ext == ".md" || ext == ".markdown" {
+		tidied, err = jt.TidyMarkdown(orig)
+		if merr, ok := err.(*jsontidier.MarkdownError); ok {
+			for _, e := range merr.Blocks {
+				fmt.Fprintf(os.Stderr, "Could not tidy %s: %s\n", file, e)
+			}
+			p.exit = 1
+			err = nil
+		}
+	} else {
+		tidied, err = jt.TidyBytes(orig)
 	}
-	jt := jsontidier.NewJSONTidier(np)
-
-	tidied, err := jt.TidyBytes(orig)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Could not tidy %s: %s\n", file, err)
 		p.exit = 1
 		return
 	}
+	p.reportDiagnostics(jt, file)
 
 	if p.stdout {
 		fmt.Print(string(tidied))
@@ -308,3 +779,118 @@ func (p *program) tidy(fi os.FileInfo, file string) {
 		fmt.Fprintf(os.Stdout, "%s is already tidy\n", file)
 	}
 }
+
+// tidyLines tidies a JSON Lines file without reading all of it into memory.
+// The tidied output is compared to the original file as it is written, and
+// unless we are in check mode it is written to a temporary file which then
+// replaces the original if anything changed.
+func (p *program) tidyLines(jt *jsontidier.JSONTidier, fi os.FileInfo, file string) {
+	in, err := os.Open(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not read file %s: %s\n", file, err)
+		p.exit = 1
+		return
+	}
+	defer in.Close()
+
+	if p.stdout {
+		err = jt.TidyLines(in, os.Stdout)
+		p.reportDiagnostics(jt, file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not tidy %s: %s\n", file, err)
+			p.exit = 1
+		}
+		return
+	}
+
+	orig, err := os.Open(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not read file %s: %s\n", file, err)
+		p.exit = 1
+		return
+	}
+	defer orig.Close()
+
+	cw := &compareWriter{r: bufio.NewReader(orig)}
+	var out io.Writer = cw
+	var tmp *os.File
+	if !p.check {
+		tmp, err = ioutil.TempFile(filepath.Dir(file), "."+filepath.Base(file)+".")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not create a temporary file for %s: %s\n", file, err)
+			p.exit = 1
+			return
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		out = io.MultiWriter(tmp, cw)
+	}
+
+	err = jt.TidyLines(in, out)
+	p.reportDiagnostics(jt, file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not tidy %s: %s\n", file, err)
+		p.exit = 1
+		return
+	}
+
+	if cw.same() {
+		if p.verbose {
+			fmt.Fprintf(os.Stdout, "%s is already tidy\n", file)
+		}
+		return
+	}
+
+	if p.check {
+		fmt.Fprintf(os.Stdout, "%s is not tidy\n", file)
+		p.exit = 1
+		return
+	}
+
+	err = tmp.Chmod(fi.Mode().Perm())
+	if err == nil {
+		err = tmp.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not write tidied JSON to %s: %s\n", file, err)
+		p.exit = 1
+		return
+	}
+
+	if p.verbose {
+		fmt.Fprintf(os.Stdout, "Tidied %s\n", file)
+	}
+}
+
+func (p *program) reportDiagnostics(jt *jsontidier.JSONTidier, file string) {
+	for _, d := range jt.Diagnostics() {
+		fmt.Fprintf(os.Stderr, "%s:%d: %s in %s\n", file, d.Line, d.Message, d.Path)
+	}
+}
+
+// compareWriter compares everything written to it with what it reads from r.
+type compareWriter struct {
+	r       *bufio.Reader
+	differs bool
+}
+
+func (cw *compareWriter) Write(b []byte) (int, error) {
+	if !cw.differs {
+		buf := make([]byte, len(b))
+		n, _ := io.ReadFull(cw.r, buf)
+		cw.differs = !bytes.Equal(buf[:n], b)
+	}
+	return len(b), nil
+}
+
+// same returns true if everything written matched everything in r.
+func (cw *compareWriter) same() bool {
+	if cw.differs {
+		return false
+	}
+	_, err := cw.r.ReadByte()
+	return err == io.EOF
+}
@@ -0,0 +1,274 @@
+// Package editorconfig finds the EditorConfig (https://editorconfig.org/)
+// properties which apply to a file.
+package editorconfig
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Finder looks up the properties for files. It caches each .editorconfig
+// file it reads, so it's cheap to use the same Finder for many files in the
+// same directory tree.
+type Finder struct {
+	files map[string]*file
+}
+
+type file struct {
+	root     bool
+	sections []section
+}
+
+type section struct {
+	re     *regexp.Regexp
+	ranges []numRange
+	props  map[string]string
+}
+
+type numRange struct {
+	min, max int
+}
+
+// NewFinder creates a new Finder.
+func NewFinder() *Finder {
+	return &Finder{files: make(map[string]*file)}
+}
+
+// Properties returns the properties which apply to the file at path. These
+// come from the .editorconfig files in the file's directory and each
+// directory above it, stopping at the first file which sets "root = true".
+// Properties from files closer to path take precedence, as do properties
+// from later sections in the same file. Property names and values are
+// lowercased.
+func (f *Finder) Properties(path string) (map[string]string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []string
+	var files []*file
+	dir := filepath.Dir(path)
+	for {
+		ec, err := f.read(dir)
+		if err != nil {
+			return nil, err
+		}
+		if ec != nil {
+			dirs = append(dirs, dir)
+			files = append(files, ec)
+			if ec.root {
+				break
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	props := make(map[string]string)
+	for i := len(files) - 1; i >= 0; i-- {
+		rel, err := filepath.Rel(dirs[i], path)
+		if err != nil {
+			return nil, err
+		}
+		rel = filepath.ToSlash(rel)
+
+		for _, s := range files[i].sections {
+			if !s.matches(rel) {
+				continue
+			}
+			for k, v := range s.props {
+				props[k] = v
+			}
+		}
+	}
+
+	return props, nil
+}
+
+func (f *Finder) read(dir string) (*file, error) {
+	if ec, ok := f.files[dir]; ok {
+		return ec, nil
+	}
+
+	path := filepath.Join(dir, ".editorconfig")
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		f.files[dir] = nil
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	ec, err := parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	f.files[dir] = ec
+
+	return ec, nil
+}
+
+func parse(b []byte) (*file, error) {
+	ec := &file{}
+	var cur *section
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if n == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			re, ranges, err := globToRegexp(line[1 : len(line)-1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", n, err)
+			}
+			ec.sections = append(ec.sections, section{re, ranges, make(map[string]string)})
+			cur = &ec.sections[len(ec.sections)-1]
+			continue
+		}
+
+		eq := strings.IndexAny(line, "=:")
+		if eq < 0 {
+			return nil, fmt.Errorf("line %d: expected a section or a key = value pair", n)
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:eq]))
+		value := strings.ToLower(strings.TrimSpace(line[eq+1:]))
+
+		if cur == nil {
+			if key == "root" {
+				ec.root = value == "true"
+			}
+			continue
+		}
+		cur.props[key] = value
+	}
+
+	return ec, scanner.Err()
+}
+
+func (s section) matches(path string) bool {
+	m := s.re.FindStringSubmatch(path)
+	if m == nil {
+		return false
+	}
+
+	for i, r := range s.ranges {
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil || n < r.min || n > r.max {
+			return false
+		}
+	}
+
+	return true
+}
+
+var numRangeRegexp = regexp.MustCompile(`^([+-]?\d+)\.\.([+-]?\d+)$`)
+
+// globToRegexp turns an EditorConfig glob into a regexp which matches paths
+// relative to the .editorconfig file's directory. Each {num1..num2} range in
+// the glob becomes a capture group in the regexp, and the ranges are returned
+// so the captured numbers can be checked.
+func globToRegexp(glob string) (*regexp.Regexp, []numRange, error) {
+	var ranges []numRange
+	var re strings.Builder
+
+	if strings.Contains(glob, "/") {
+		glob = strings.TrimPrefix(glob, "/")
+		re.WriteString("^")
+	} else {
+		re.WriteString("^(?:.*/)?")
+	}
+
+	braces := 0
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				re.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+			}
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				re.WriteString(".*")
+				i++
+			} else {
+				re.WriteString("[^/]*")
+			}
+		case '?':
+			re.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				re.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + strings.Replace(class, `\`, `\\`, -1) + "]")
+			i += end + 1
+		case '{':
+			end := strings.IndexByte(glob[i+1:], '}')
+			if end >= 0 {
+				if m := numRangeRegexp.FindStringSubmatch(glob[i+1 : i+1+end]); m != nil {
+					min, _ := strconv.Atoi(m[1])
+					max, _ := strconv.Atoi(m[2])
+					ranges = append(ranges, numRange{min, max})
+					re.WriteString(`([+-]?\d+)`)
+					i += end + 1
+					continue
+				}
+			}
+			if end < 0 || !strings.Contains(glob[i+1:i+1+end], ",") {
+				re.WriteString(`\{`)
+				continue
+			}
+			braces++
+			re.WriteString("(?:")
+		case '}':
+			if braces > 0 {
+				braces--
+				re.WriteString(")")
+			} else {
+				re.WriteString(`\}`)
+			}
+		case ',':
+			if braces > 0 {
+				re.WriteString("|")
+			} else {
+				re.WriteString(",")
+			}
+		default:
+			re.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	re.WriteString("$")
+
+	r, err := regexp.Compile(re.String())
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid glob %q: %s", glob, err)
+	}
+
+	return r, ranges, nil
+}
@@ -0,0 +1,94 @@
+package editorconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGlobToRegexp(t *testing.T) {
+	tests := []struct {
+		glob    string
+		path    string
+		matches bool
+	}{
+		{"*", "a.json", true},
+		{"*", "dir/a.json", true},
+		{"*.json", "dir/sub/a.json", true},
+		{"*.json", "a.jsonc", false},
+		{"*.{json,jsonc}", "a.jsonc", true},
+		{"dir/*.json", "dir/a.json", true},
+		{"dir/*.json", "dir/sub/a.json", false},
+		{"dir/**.json", "dir/sub/a.json", true},
+		{"/dir/*.json", "dir/a.json", true},
+		{"/dir/*.json", "other/dir/a.json", false},
+		{"a?.json", "ab.json", true},
+		{"[ab].json", "b.json", true},
+		{"[!ab].json", "b.json", false},
+		{"file{1..10}.json", "file7.json", true},
+		{"file{1..10}.json", "file11.json", false},
+		{"\\*.json", "*.json", true},
+		{"\\*.json", "a.json", false},
+	}
+
+	for _, test := range tests {
+		re, ranges, err := globToRegexp(test.glob)
+		assert.Nil(t, err, "no error converting %s", test.glob)
+		s := section{re: re, ranges: ranges}
+		assert.Equal(t, test.matches, s.matches(test.path), "%s matches %s", test.glob, test.path)
+	}
+}
+
+func TestProperties(t *testing.T) {
+	dir, err := ioutil.TempDir("", "editorconfig")
+	assert.Nil(t, err, "no error creating temp dir")
+	defer os.RemoveAll(dir)
+
+	sub := filepath.Join(dir, "sub")
+	assert.Nil(t, os.Mkdir(sub, 0755), "no error creating sub dir")
+
+	writeFile(t, filepath.Join(dir, ".editorconfig"), `
+root = true
+
+[*]
+indent_style = space
+indent_size = 4
+end_of_line = LF
+
+[*.json]
+indent_size = 2
+`)
+	writeFile(t, filepath.Join(sub, ".editorconfig"), `
+# Not the root.
+[*.json]
+indent_style = tab
+`)
+
+	f := NewFinder()
+
+	props, err := f.Properties(filepath.Join(dir, "a.json"))
+	assert.Nil(t, err, "no error getting properties")
+	assert.Equal(
+		t,
+		map[string]string{"indent_style": "space", "indent_size": "2", "end_of_line": "lf"},
+		props,
+		"got properties for a.json",
+	)
+
+	props, err = f.Properties(filepath.Join(sub, "b.json"))
+	assert.Nil(t, err, "no error getting properties")
+	assert.Equal(
+		t,
+		map[string]string{"indent_style": "tab", "indent_size": "2", "end_of_line": "lf"},
+		props,
+		"got properties for sub/b.json",
+	)
+}
+
+func writeFile(t *testing.T, path, content string) {
+	err := ioutil.WriteFile(path, []byte(content), 0644)
+	assert.Nil(t, err, "no error writing %s", path)
+}
@@ -0,0 +1,117 @@
+package jsontidier
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// appendCanonical appends v to buf using the JSON Canonicalization Scheme
+// from RFC 8785. Object keys are sorted by their UTF-16 code units, numbers
+// are serialized the way ECMAScript does, and there is no whitespace.
+func appendCanonical(buf []byte, v interface{}) ([]byte, error) {
+	var err error
+	switch v := v.(type) {
+	case *JSONTidier:
+		keys := make([]string, len(v.keyOrder))
+		copy(keys, v.keyOrder)
+		sort.Slice(keys, func(i, j int) bool {
+			return compareUTF16(keys[i], keys[j]) < 0
+		})
+
+		buf = append(buf, '{')
+		for i, k := range keys {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			if _, ok := v.ourMap[k].(duplicates); ok {
+				return buf, fmt.Errorf("duplicate key %q cannot be written as canonical JSON", k)
+			}
+			buf = appendString(buf, k, false, false)
+			buf = append(buf, ':')
+			buf, err = appendCanonical(buf, v.ourMap[k])
+			if err != nil {
+				return buf, err
+			}
+		}
+		return append(buf, '}'), nil
+	case []interface{}:
+		buf = append(buf, '[')
+		for i, e := range v {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf, err = appendCanonical(buf, uncommented(e))
+			if err != nil {
+				return buf, err
+			}
+		}
+		return append(buf, ']'), nil
+	case json.Number, rawNumber:
+		num, _ := numberValue(v)
+		n, err := canonicalNumber(num)
+		if err != nil {
+			return buf, err
+		}
+		return append(buf, n...), nil
+	case bool:
+		return strconv.AppendBool(buf, v), nil
+	case nil:
+		return append(buf, "null"...), nil
+	}
+
+	if s, ok := stringValue(v); ok {
+		return appendString(buf, s, false, false), nil
+	}
+	return buf, fmt.Errorf("cannot canonicalize a value of type %T", v)
+}
+
+func compareUTF16(a, b string) int {
+	au := utf16.Encode([]rune(a))
+	bu := utf16.Encode([]rune(b))
+	for i := 0; i < len(au) && i < len(bu); i++ {
+		if au[i] != bu[i] {
+			return int(au[i]) - int(bu[i])
+		}
+	}
+	return len(au) - len(bu)
+}
+
+// canonicalNumber serializes a number the way ECMAScript's
+// Number.prototype.toString does, as required by RFC 8785. Since this goes
+// through a float64, numbers which cannot be represented exactly as a
+// float64 will lose precision, and numbers which are too large are an error.
+func canonicalNumber(n json.Number) (string, error) {
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return "", fmt.Errorf("the number %s cannot be represented in canonical JSON", n)
+	}
+
+	if f == 0 {
+		return "0", nil
+	}
+
+	sign := ""
+	if f < 0 {
+		f = -f
+		sign = "-"
+	}
+
+	format := byte('e')
+	if f >= 1e-6 && f < 1e21 {
+		format = 'f'
+	}
+	s := strconv.FormatFloat(f, format, -1, 64)
+
+	// Go writes exponents with at least two digits, like "1e+09", but
+	// ECMAScript does not.
+	if e := strings.IndexByte(s, 'e'); e > 0 && s[e+2] == '0' {
+		s = s[:e+2] + s[e+3:]
+	}
+
+	return sign + s, nil
+}
@@ -0,0 +1,72 @@
+package jsontidier
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonical(t *testing.T) {
+	// This is the example from section 3.2.3 of RFC 8785.
+	orig := `{
+    "\u20ac": "Euro Sign",
+    "\r": "Carriage Return",
+    "\ufb33": "Hebrew Letter Dalet With Dagesh",
+    "1": "One",
+    "\ud83d\ude00": "Emoji: Grinning Face",
+    "\u0080": "Control",
+    "\u00f6": "Latin Small Letter O With Diaeresis"
+}`
+
+	expect := "{\"\\r\":\"Carriage Return\",\"1\":\"One\",\"\u0080\":\"Control\",\"\u00f6\":\"Latin Small Letter O With Diaeresis\",\"\u20ac\":\"Euro Sign\",\"\U0001f600\":\"Emoji: Grinning Face\",\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\"}"
+
+	compareTidied(t, NewParams{Canonical: true}, orig, expect)
+
+	// The example from section 3.2.2 of RFC 8785, along with a keyOrder and
+	// arraySort to show that the keys are always sorted but the arrays are
+	// only sorted when asked.
+	orig = `{
+    "numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+    "string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+    "literals": [null, true, false],
+    "sorted": [3, 1, 2]
+}`
+
+	expect = `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"sorted":[1,2,3],"string":"€$\u000f\nA'B\"\\\\\"/"}`
+
+	compareTidied(
+		t,
+		NewParams{
+			Canonical: true,
+			KeyOrder:  map[string][]string{"$": {"string"}},
+			ArraySort: []string{"$.sorted"},
+		},
+		orig,
+		expect,
+	)
+}
+
+func TestCanonicalNumber(t *testing.T) {
+	tests := map[string]string{
+		"0":                      "0",
+		"-0":                     "0",
+		"1e21":                   "1e+21",
+		"1e20":                   "100000000000000000000",
+		"0.000001":               "0.000001",
+		"0.0000001":              "1e-7",
+		"-1.5E+2":                "-150",
+		"9007199254740993":       "9007199254740992",
+		"5e-324":                 "5e-324",
+		"1.7976931348623157e308": "1.7976931348623157e+308",
+	}
+
+	for n, expect := range tests {
+		got, err := canonicalNumber(json.Number(n))
+		assert.Nil(t, err, "no error canonicalizing %s", n)
+		assert.Equal(t, expect, got, "canonical form of %s", n)
+	}
+
+	_, err := canonicalNumber(json.Number("1e400"))
+	assert.NotNil(t, err, "error canonicalizing 1e400")
+}
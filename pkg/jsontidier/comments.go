@@ -0,0 +1,63 @@
+package jsontidier
+
+import (
+	"encoding/json"
+)
+
+// comments holds the comments attached to an object member, an array element
+// or the whole document. The before comments are written on their own lines
+// before it, and the after comments at the end of its last line. If it is an
+// array or object, the end comments are written on their own lines before its
+// closing bracket.
+type comments struct {
+	before []string
+	after  []string
+	end    []string
+}
+
+func (c *comments) endComments() []string {
+	if c == nil {
+		return nil
+	}
+	return c.end
+}
+
+// commented is an array element which has comments attached to it. Elements
+// without comments are stored as is.
+type commented struct {
+	value    interface{}
+	comments *comments
+}
+
+func (c commented) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.value)
+}
+
+// uncommented returns v without any comments attached to it.
+func uncommented(v interface{}) interface{} {
+	if c, ok := v.(commented); ok {
+		return c.value
+	}
+	return v
+}
+
+// splitComments splits the comments found before a token into those which
+// were on the same line as the previous token and the rest.
+func splitComments(cs []comment) (sameLine, rest []string) {
+	for _, c := range cs {
+		if c.newlines == 0 && len(rest) == 0 {
+			sameLine = append(sameLine, c.text)
+		} else {
+			rest = append(rest, c.text)
+		}
+	}
+	return sameLine, rest
+}
+
+func commentTexts(cs []comment) []string {
+	var texts []string
+	for _, c := range cs {
+		texts = append(texts, c.text)
+	}
+	return texts
+}
@@ -0,0 +1,88 @@
+package jsontidier
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComments(t *testing.T) {
+	orig := `// Top comment
+{
+    // About zeta
+    "zeta": 1, // trailing zeta
+    /* block
+       comment */
+    "alpha": [
+        "b", // about b
+        // about a
+        "a"
+        // end of alpha
+    ],
+    "empty": {
+        // nothing here
+    },
+    "nested": {"x": 1 /* x */, "y": [1, 2]}
+    // end of root
+}
+// after root`
+
+	expect := `// Top comment
+{
+    /* block
+       comment */
+    "alpha": [
+        // about a
+        "a",
+        "b" // about b
+        // end of alpha
+    ],
+    "empty": {
+        // nothing here
+    },
+    "nested": {
+        "x": 1, /* x */
+        "y": [1, 2]
+    },
+    // About zeta
+    "zeta": 1 // trailing zeta
+    // end of root
+}
+// after root
+`
+
+	compareTidied(
+		t,
+		NewParams{
+			JSONC:        true,
+			SortKeys:     "alphabetical",
+			ArraySort:    []string{"$.alpha"},
+			MaxLineWidth: 80,
+		},
+		orig,
+		expect,
+	)
+
+	expect = `{"zeta":1,"alpha":["a","b"],"empty":{},"nested":{"x":1,"y":[1,2]}}
+`
+
+	compareTidied(t, NewParams{JSONC: true, ArraySort: []string{"$.alpha"}, Minify: true}, orig, expect)
+
+	expect = `{"alpha":["a","b"],"empty":{},"nested":{"x":1,"y":[1,2]},"zeta":1}`
+
+	compareTidied(t, NewParams{JSONC: true, ArraySort: []string{"$.alpha"}, Canonical: true}, orig, expect)
+}
+
+func TestCommentsCRLF(t *testing.T) {
+	orig := "{\r\n  /* block\r\n     comment */\r\n  \"a\": 1\r\n}\r\n"
+	expect := "{\r\n    /* block\r\n     comment */\r\n    \"a\": 1\r\n}\r\n"
+
+	jt := NewJSONTidier(NewParams{JSONC: true})
+	tidied, err := jt.TidyString(orig)
+	assert.Nil(t, err, "no error calling TidyString")
+	assert.Equal(t, expect, tidied, "got expected tidied JSON")
+
+	tidied, err = jt.TidyString(tidied)
+	assert.Nil(t, err, "no error calling TidyString on tidied JSON")
+	assert.Equal(t, expect, tidied, "tidying again gives the same output")
+}
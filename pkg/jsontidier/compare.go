@@ -0,0 +1,169 @@
+package jsontidier
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+)
+
+// These are the ranks used to order values of different types when doing a
+// deep comparison.
+const (
+	rankNull = iota
+	rankFalse
+	rankTrue
+	rankNumber
+	rankString
+	rankArray
+	rankObject
+)
+
+func typeRank(v interface{}) int {
+	switch v := v.(type) {
+	case nil:
+		return rankNull
+	case bool:
+		if v {
+			return rankTrue
+		}
+		return rankFalse
+	case json.Number, rawNumber:
+		return rankNumber
+	case string, rawString:
+		return rankString
+	case []interface{}:
+		return rankArray
+	}
+	return rankObject
+}
+
+// compareValues does a deep comparison of two JSON values. Values of
+// different types are ordered null, false, true, numbers, strings, arrays and
+// then objects. Numbers are compared numerically, and strings are compared
+// case-insensitively with ties broken by a case-sensitive comparison. Arrays
+// are compared element by element, with a shorter array sorting before any
+// longer array it is a prefix of. Objects are compared the same way, member by
+// member in their tidied key order, comparing each member's key and then its
+// value. The values of a duplicated key are compared like an array.
+func compareValues(a, b interface{}) int {
+	a = uncommented(a)
+	b = uncommented(b)
+	if d, ok := a.(duplicates); ok {
+		a = []interface{}(d)
+	}
+	if d, ok := b.(duplicates); ok {
+		b = []interface{}(d)
+	}
+	ar := typeRank(a)
+	br := typeRank(b)
+	if ar != br {
+		return ar - br
+	}
+
+	switch a := a.(type) {
+	case json.Number, rawNumber:
+		an, _ := numberValue(a)
+		bn, _ := numberValue(b)
+		return compareNumbers(an, bn)
+	case string, rawString:
+		as, _ := stringValue(a)
+		bs, _ := stringValue(b)
+		return compareStrings(as, bs)
+	case []interface{}:
+		b := b.([]interface{})
+		for i := 0; i < len(a) && i < len(b); i++ {
+			if c := compareValues(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+		return len(a) - len(b)
+	case *JSONTidier:
+		b := b.(*JSONTidier)
+		for i := 0; i < len(a.keyOrder) && i < len(b.keyOrder); i++ {
+			ak := a.keyOrder[i]
+			bk := b.keyOrder[i]
+			if c := compareStrings(ak, bk); c != 0 {
+				return c
+			}
+			if c := compareValues(a.ourMap[ak], b.ourMap[bk]); c != 0 {
+				return c
+			}
+		}
+		return len(a.keyOrder) - len(b.keyOrder)
+	}
+
+	return 0
+}
+
+func compareStrings(a, b string) int {
+	if c := strings.Compare(strings.ToLower(a), strings.ToLower(b)); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
+
+// compareNatural compares two strings case-insensitively, except that runs of
+// digits are compared by their numeric value, so "item2" sorts before
+// "item10". If the strings are otherwise equal, the one with fewer leading
+// zeros sorts first, followed by a plain case-sensitive comparison.
+func compareNatural(a, b string) int {
+	al := strings.ToLower(a)
+	bl := strings.ToLower(b)
+	zeros := 0
+	for len(al) > 0 && len(bl) > 0 {
+		ad := digitPrefix(al)
+		bd := digitPrefix(bl)
+		if ad > 0 && bd > 0 {
+			an := strings.TrimLeft(al[:ad], "0")
+			bn := strings.TrimLeft(bl[:bd], "0")
+			if c := compareNumeric(an, bn); c != 0 {
+				return c
+			}
+			if zeros == 0 {
+				zeros = ad - bd
+			}
+			al = al[ad:]
+			bl = bl[bd:]
+			continue
+		}
+
+		if al[0] != bl[0] {
+			return int(al[0]) - int(bl[0])
+		}
+		al = al[1:]
+		bl = bl[1:]
+	}
+
+	if len(al) != len(bl) {
+		return len(al) - len(bl)
+	}
+	if zeros != 0 {
+		return zeros
+	}
+	return strings.Compare(a, b)
+}
+
+func digitPrefix(s string) int {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return i
+}
+
+// compareNumbers compares two numbers exactly, without converting them to
+// floats. Numbers which cannot be parsed are compared as strings after all
+// of the numbers which can be.
+func compareNumbers(a, b json.Number) int {
+	ar, aok := new(big.Rat).SetString(string(a))
+	br, bok := new(big.Rat).SetString(string(b))
+	if aok && bok {
+		return ar.Cmp(br)
+	} else if aok != bok {
+		if aok {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(string(a), string(b))
+}
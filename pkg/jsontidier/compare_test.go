@@ -0,0 +1,116 @@
+package jsontidier
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeepArraySorting(t *testing.T) {
+	orig := `{
+    "pairs": [ [2, 1], [1, 5], [1, 2], [1] ],
+    "mixed": [ {"b": 1}, "x", [1], 10, null, true, 9, false, {"a": 2}, {"a": 1, "b": 1}, {"a": 1} ]
+}`
+
+	expect := `{
+    "pairs": [
+        [
+            1
+        ],
+        [
+            1,
+            2
+        ],
+        [
+            1,
+            5
+        ],
+        [
+            2,
+            1
+        ]
+    ],
+    "mixed": [
+        null,
+        false,
+        true,
+        9,
+        10,
+        "x",
+        [
+            1
+        ],
+        {
+            "a": 1
+        },
+        {
+            "a": 1,
+            "b": 1
+        },
+        {
+            "a": 2
+        },
+        {
+            "b": 1
+        }
+    ]
+}
+`
+
+	compareTidied(
+		t,
+		NewParams{ArraySortRules: []ArraySortRule{
+			{Path: "$.pairs", Comparator: "deep"},
+			{Path: "$.mixed", Comparator: "deep"},
+		}},
+		orig,
+		expect,
+	)
+}
+
+func TestCompareNumbers(t *testing.T) {
+	tests := []struct {
+		a, b   string
+		expect int
+	}{
+		{"9", "10", -1},
+		{"1.50E+2", "150", 0},
+		{"-0", "0", 0},
+		{"100000000000000000000001", "100000000000000000000000", 1},
+		{"-1.5", "-1.25", -1},
+	}
+
+	for _, test := range tests {
+		assert.Equal(
+			t,
+			test.expect,
+			compareNumbers(json.Number(test.a), json.Number(test.b)),
+			"compare %s to %s", test.a, test.b,
+		)
+	}
+}
+
+func TestCompareNatural(t *testing.T) {
+	tests := []struct {
+		a, b   string
+		expect int
+	}{
+		{"item2", "item10", -1},
+		{"Item2", "item10", -1},
+		{"item10", "item9b", 1},
+		{"a", "B", -1},
+		{"item02", "item2", 1},
+		{"x1y", "x1", 1},
+	}
+
+	for _, test := range tests {
+		c := compareNatural(test.a, test.b)
+		if c > 0 {
+			c = 1
+		} else if c < 0 {
+			c = -1
+		}
+		assert.Equal(t, test.expect, c, "compare %s to %s", test.a, test.b)
+	}
+}
@@ -0,0 +1,741 @@
+package jsontidier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// decoder is a replacement for json.Decoder which has the same Token and
+// More methods, but which also keeps track of the raw text and position of
+// each token. Numbers are always returned as json.Number. If comments is
+// true then "//" and "/* */" comments are allowed anywhere whitespace is. If
+// json5 is true then the decoder accepts JSON5, which also has comments and
+// trailing commas. If trailingCommas is true then it accepts trailing commas
+// in arrays and objects. If stream is true then the record separators from
+// RFC 7464 are treated as whitespace.
+type decoder struct {
+	data      []byte
+	pos       int
+	line      int
+	lineStart int
+	state     int
+	stack     []int
+	comments  bool
+	json5     bool
+	stream    bool
+	err       error
+
+	trailingCommas bool
+
+	// If More skipped a trailing comma, this is its line and column.
+	commaLine int
+	commaCol  int
+
+	// These are the problems that the parser fixed while reading the input.
+	diagnostics []Diagnostic
+
+	// These are the whitespace before the first token on each line which
+	// starts with a token, and the last line this was recorded for.
+	indents    []string
+	indentLine int
+
+	// These are the comments skipped since the last token was returned, and
+	// the number of newlines skipped since the last token or comment.
+	pending []comment
+	gap     int
+
+	// These are set for each token returned by Token. The tokBlankLine is
+	// true if there is a blank line between this token and the previous one,
+	// not counting lines which only contain comments, and tokComments are the
+	// comments between them.
+	raw          []byte
+	tokPos       int
+	tokLine      int
+	tokCol       int
+	tokBlankLine bool
+	tokComments  []comment
+}
+
+// comment is the text of a comment, including the "//" or "/*" and "*/",
+// along with the number of newlines between it and the token or comment
+// before it.
+type comment struct {
+	text     string
+	newlines int
+}
+
+// These are the same states that json.Decoder uses to keep track of where it
+// is in the token stream.
+const (
+	tokenTopValue = iota
+	tokenArrayStart
+	tokenArrayValue
+	tokenArrayComma
+	tokenObjectStart
+	tokenObjectKey
+	tokenObjectColon
+	tokenObjectValue
+	tokenObjectComma
+)
+
+func newDecoder(data []byte) *decoder {
+	return &decoder{
+		data: data,
+		line: 1,
+	}
+}
+
+// More reports whether there is another element in the current array or
+// object being parsed.
+func (d *decoder) More() bool {
+	d.skipSpace()
+
+	// If trailing commas are allowed we have to look past a comma to see if
+	// there is another element.
+	line, col := d.line, d.pos-d.lineStart+1
+	if d.allowTrailingComma() && d.peek() == ',' {
+		switch d.state {
+		case tokenArrayComma:
+			d.pos++
+			d.state = tokenArrayValue
+			d.skipSpace()
+		case tokenObjectComma:
+			d.pos++
+			d.state = tokenObjectKey
+			d.skipSpace()
+		}
+	}
+
+	if d.pos >= len(d.data) {
+		return false
+	}
+	c := d.data[d.pos]
+	if c != ']' && c != '}' {
+		return true
+	}
+	if d.state == tokenArrayValue || d.state == tokenObjectKey {
+		d.commaLine, d.commaCol = line, col
+	}
+	return false
+}
+
+func (d *decoder) allowTrailingComma() bool {
+	return d.json5 || d.trailingCommas
+}
+
+// Token returns the next JSON token in the input stream. At the end of the
+// input stream, Token returns nil, io.EOF. Commas and colons are elided.
+func (d *decoder) Token() (json.Token, error) {
+	t, err := d.token()
+	d.tokBlankLine = d.gap > 1
+	for _, c := range d.pending {
+		if c.newlines > 1 {
+			d.tokBlankLine = true
+		}
+	}
+	d.tokComments = d.pending
+	d.pending = nil
+	d.gap = 0
+	d.commaLine, d.commaCol = 0, 0
+	return t, err
+}
+
+func (d *decoder) token() (json.Token, error) {
+	for {
+		d.skipSpace()
+		if d.err != nil {
+			return nil, d.err
+		}
+		if d.pos >= len(d.data) {
+			if d.state != tokenTopValue || len(d.stack) > 0 {
+				return nil, d.errorf("unexpected end of JSON input")
+			}
+			return nil, io.EOF
+		}
+
+		d.startToken()
+		c := d.data[d.pos]
+		switch c {
+		case '[':
+			if !d.valueAllowed() {
+				return nil, d.unexpected()
+			}
+			d.pos++
+			d.stack = append(d.stack, d.state)
+			d.state = tokenArrayStart
+			return d.delim(c), nil
+		case ']':
+			if d.state != tokenArrayStart && d.state != tokenArrayComma && !(d.allowTrailingComma() && d.state == tokenArrayValue) {
+				return nil, d.unexpected()
+			}
+			d.pos++
+			d.pop()
+			return d.delim(c), nil
+		case '{':
+			if !d.valueAllowed() {
+				return nil, d.unexpected()
+			}
+			d.pos++
+			d.stack = append(d.stack, d.state)
+			d.state = tokenObjectStart
+			return d.delim(c), nil
+		case '}':
+			if d.state != tokenObjectStart && d.state != tokenObjectComma && !(d.allowTrailingComma() && d.state == tokenObjectKey) {
+				return nil, d.unexpected()
+			}
+			d.pos++
+			d.pop()
+			return d.delim(c), nil
+		case ':':
+			if d.state != tokenObjectColon {
+				return nil, d.unexpected()
+			}
+			d.pos++
+			d.state = tokenObjectValue
+			continue
+		case ',':
+			if d.state == tokenArrayComma {
+				d.pos++
+				d.state = tokenArrayValue
+				continue
+			}
+			if d.state == tokenObjectComma {
+				d.pos++
+				d.state = tokenObjectKey
+				continue
+			}
+			return nil, d.unexpected()
+		}
+
+		if d.state == tokenObjectStart || d.state == tokenObjectKey {
+			s, err := d.readKey()
+			if err != nil {
+				return nil, err
+			}
+			d.state = tokenObjectColon
+			return s, nil
+		}
+
+		if !d.valueAllowed() {
+			return nil, d.unexpected()
+		}
+		t, err := d.readScalar()
+		if err != nil {
+			return nil, err
+		}
+		d.valueEnd()
+		return t, nil
+	}
+}
+
+func (d *decoder) valueAllowed() bool {
+	switch d.state {
+	case tokenTopValue, tokenArrayStart, tokenArrayValue, tokenObjectValue:
+		return true
+	}
+	return false
+}
+
+func (d *decoder) valueEnd() {
+	switch d.state {
+	case tokenArrayStart, tokenArrayValue:
+		d.state = tokenArrayComma
+	case tokenObjectValue:
+		d.state = tokenObjectComma
+	}
+}
+
+func (d *decoder) pop() {
+	d.state = d.stack[len(d.stack)-1]
+	d.stack = d.stack[:len(d.stack)-1]
+	d.valueEnd()
+}
+
+func (d *decoder) delim(c byte) json.Delim {
+	d.raw = d.data[d.pos-1 : d.pos]
+	return json.Delim(c)
+}
+
+func (d *decoder) startToken() {
+	d.tokPos = d.pos
+	d.tokLine = d.line
+	d.tokCol = d.pos - d.lineStart + 1
+
+	if d.line != d.indentLine {
+		d.indentLine = d.line
+		prefix := d.data[d.lineStart:d.pos]
+		if len(bytes.Trim(prefix, " \t")) == 0 {
+			d.indents = append(d.indents, string(prefix))
+		}
+	}
+}
+
+func (d *decoder) skipSpace() {
+	for d.pos < len(d.data) {
+		switch d.data[d.pos] {
+		case '\n':
+			d.line++
+			d.lineStart = d.pos + 1
+			d.gap++
+		case ' ', '\t', '\r':
+		case '\v', '\f':
+			if !d.json5 {
+				return
+			}
+		case recordSeparator:
+			if !d.stream {
+				return
+			}
+		case '/':
+			if !d.comments || !d.readComment() {
+				return
+			}
+			continue
+		default:
+			if d.json5 && d.data[d.pos] >= utf8.RuneSelf {
+				r, size := utf8.DecodeRune(d.data[d.pos:])
+				if isJSON5Space(r) {
+					d.pos += size
+					continue
+				}
+			}
+			return
+		}
+		d.pos++
+	}
+}
+
+// recordSeparator starts each document in a JSON text sequence (RFC 7464).
+const recordSeparator = 0x1e
+
+// isJSON5Space returns true for the non-ASCII characters which JSON5 treats
+// as whitespace.
+func isJSON5Space(r rune) bool {
+	return r == '\u2028' || r == '\u2029' || r == '\ufeff' || unicode.Is(unicode.Zs, r)
+}
+
+// readComment reads a comment starting at the current position and adds it
+// to the pending comments. It returns false if there is no comment here.
+func (d *decoder) readComment() bool {
+	start := d.pos
+	switch d.peekAt(1) {
+	case '/':
+		for d.pos < len(d.data) && d.data[d.pos] != '\n' {
+			d.pos++
+		}
+	case '*':
+		d.pos += 2
+		for {
+			if d.pos >= len(d.data) {
+				if d.err == nil {
+					d.err = d.errorf("unexpected end of JSON input in comment")
+				}
+				return true
+			}
+			if d.data[d.pos] == '*' && d.peekAt(1) == '/' {
+				d.pos += 2
+				break
+			}
+			if d.data[d.pos] == '\n' {
+				d.line++
+				d.lineStart = d.pos + 1
+			}
+			d.pos++
+		}
+	default:
+		return false
+	}
+
+	text := strings.ReplaceAll(string(d.data[start:d.pos]), "\r\n", "\n")
+	text = strings.TrimRight(text, "\r")
+	d.pending = append(d.pending, comment{text, d.gap})
+	d.gap = 0
+	return true
+}
+
+// readKey reads an object key, which can also be a single quoted string or
+// an identifier in JSON5.
+func (d *decoder) readKey() (string, error) {
+	c := d.data[d.pos]
+	if c == '"' || (d.json5 && c == '\'') {
+		return d.readString()
+	}
+
+	if d.json5 {
+		start := d.pos
+		for d.pos < len(d.data) {
+			r, size := utf8.DecodeRune(d.data[d.pos:])
+			if !isIdentifierRune(r, d.pos == start) {
+				break
+			}
+			d.pos += size
+		}
+		if d.pos > start {
+			d.raw = d.data[start:d.pos]
+			return string(d.raw), nil
+		}
+	}
+
+	return "", d.unexpected()
+}
+
+// isIdentifierRune returns true if r can be part of an ECMAScript identifier.
+// Unicode escapes are not supported.
+func isIdentifierRune(r rune, first bool) bool {
+	if r == '$' || r == '_' || unicode.IsLetter(r) || unicode.Is(unicode.Nl, r) {
+		return true
+	}
+	if first {
+		return false
+	}
+	return unicode.In(r, unicode.Mn, unicode.Mc, unicode.Nd, unicode.Pc) || r == '\u200c' || r == '\u200d'
+}
+
+func (d *decoder) readScalar() (json.Token, error) {
+	start := d.pos
+	c := d.data[d.pos]
+	switch {
+	case c == '"' || (d.json5 && c == '\''):
+		return d.readString()
+	case d.json5 && (c == '+' || c == '-' || c == '.' || c == 'I' || c == 'N' || (c >= '0' && c <= '9')):
+		return d.readJSON5Number()
+	case c == '-' || (c >= '0' && c <= '9'):
+		return d.readNumber()
+	}
+
+	for _, lit := range []struct {
+		text  string
+		value json.Token
+	}{{"true", true}, {"false", false}, {"null", nil}} {
+		end := start + len(lit.text)
+		if end <= len(d.data) && string(d.data[start:end]) == lit.text {
+			d.pos = end
+			d.raw = d.data[start:end]
+			return lit.value, nil
+		}
+	}
+
+	return nil, d.unexpected()
+}
+
+func (d *decoder) readNumber() (json.Token, error) {
+	start := d.pos
+	if d.peek() == '-' {
+		d.pos++
+	}
+
+	if d.peek() == '0' {
+		d.pos++
+	} else if !d.digits() {
+		return nil, d.expected("digit")
+	}
+	if d.peek() == '.' {
+		d.pos++
+		if !d.digits() {
+			return nil, d.expected("digit after decimal point")
+		}
+	}
+	if c := d.peek(); c == 'e' || c == 'E' {
+		d.pos++
+		if c := d.peek(); c == '+' || c == '-' {
+			d.pos++
+		}
+		if !d.digits() {
+			return nil, d.expected("digit in exponent")
+		}
+	}
+
+	d.raw = d.data[start:d.pos]
+	return json.Number(d.raw), nil
+}
+
+// readJSON5Number reads a JSON5 number, which can have a leading "+",
+// leading or trailing decimal points, or be hexadecimal, Infinity or NaN.
+// The number returned is the equivalent JSON number, except for Infinity and
+// NaN, which are returned as is.
+func (d *decoder) readJSON5Number() (json.Token, error) {
+	start := d.pos
+	sign := ""
+	if c := d.peek(); c == '+' || c == '-' {
+		if c == '-' {
+			sign = "-"
+		}
+		d.pos++
+	}
+
+	for _, lit := range []string{"Infinity", "NaN"} {
+		if bytes.HasPrefix(d.data[d.pos:], []byte(lit)) {
+			d.pos += len(lit)
+			d.raw = d.data[start:d.pos]
+			return json.Number(sign + lit), nil
+		}
+	}
+
+	if d.peek() == '0' && (d.peekAt(1) == 'x' || d.peekAt(1) == 'X') {
+		d.pos += 2
+		hexStart := d.pos
+		for d.pos < len(d.data) && strings.IndexByte("0123456789abcdefABCDEF", d.data[d.pos]) >= 0 {
+			d.pos++
+		}
+		if d.pos == hexStart {
+			return nil, d.expected("hexadecimal digit")
+		}
+		n, _ := new(big.Int).SetString(string(d.data[hexStart:d.pos]), 16)
+		d.raw = d.data[start:d.pos]
+		if n.Sign() == 0 {
+			sign = ""
+		}
+		return json.Number(sign + n.String()), nil
+	}
+
+	intStart := d.pos
+	if d.peek() == '0' {
+		d.pos++
+	} else {
+		d.digits()
+	}
+	intPart := string(d.data[intStart:d.pos])
+	fracPart := ""
+	if d.peek() == '.' {
+		d.pos++
+		fracStart := d.pos
+		d.digits()
+		fracPart = string(d.data[fracStart:d.pos])
+	}
+	if intPart == "" && fracPart == "" {
+		return nil, d.expected("digit")
+	}
+	expStart := d.pos
+	if c := d.peek(); c == 'e' || c == 'E' {
+		d.pos++
+		if c := d.peek(); c == '+' || c == '-' {
+			d.pos++
+		}
+		if !d.digits() {
+			return nil, d.expected("digit in exponent")
+		}
+	}
+
+	n := sign
+	if intPart == "" {
+		n += "0"
+	} else {
+		n += intPart
+	}
+	if fracPart != "" {
+		n += "." + fracPart
+	}
+	n += string(d.data[expStart:d.pos])
+
+	d.raw = d.data[start:d.pos]
+	return json.Number(n), nil
+}
+
+func (d *decoder) digits() bool {
+	start := d.pos
+	for d.pos < len(d.data) && d.data[d.pos] >= '0' && d.data[d.pos] <= '9' {
+		d.pos++
+	}
+	return d.pos > start
+}
+
+func (d *decoder) peek() byte {
+	return d.peekAt(0)
+}
+
+func (d *decoder) peekAt(offset int) byte {
+	if d.pos+offset >= len(d.data) {
+		return 0
+	}
+	return d.data[d.pos+offset]
+}
+
+// readString reads a quoted string, returning the unescaped value. Invalid
+// UTF-8 and unpaired surrogates are replaced with U+FFFD, as encoding/json
+// does. In JSON5 the string can also be single quoted.
+func (d *decoder) readString() (string, error) {
+	start := d.pos
+	quote := d.data[d.pos]
+	d.pos++
+
+	var s []byte
+	for {
+		if d.pos >= len(d.data) {
+			return "", d.errorf("unexpected end of JSON input in string")
+		}
+
+		c := d.data[d.pos]
+		switch {
+		case c == quote:
+			d.pos++
+			// A JSON5 line continuation can contain a CRLF, which is written
+			// as LF like every other line ending we write.
+			d.raw = d.data[start:d.pos]
+			if bytes.Contains(d.raw, []byte("\r\n")) {
+				d.raw = bytes.ReplaceAll(d.raw, []byte("\r\n"), []byte("\n"))
+			}
+			return string(s), nil
+		case c == '\\':
+			r, err := d.readEscape()
+			if err != nil {
+				return "", err
+			}
+			if r >= 0 {
+				s = appendRune(s, r)
+			}
+		case c < 0x20 && (!d.json5 || c == '\n' || c == '\r'):
+			return "", d.errorf("invalid character %q in string literal", c)
+		case c < utf8.RuneSelf:
+			s = append(s, c)
+			d.pos++
+		default:
+			r, size := utf8.DecodeRune(d.data[d.pos:])
+			s = appendRune(s, r)
+			d.pos += size
+		}
+	}
+}
+
+// readEscape reads an escape sequence in a string. This returns -1 for a
+// JSON5 line continuation, which does not add anything to the string.
+func (d *decoder) readEscape() (rune, error) {
+	d.pos++
+	if d.pos >= len(d.data) {
+		return 0, d.errorf("unexpected end of JSON input in string escape")
+	}
+
+	c := d.data[d.pos]
+	d.pos++
+	switch c {
+	case '"', '\\', '/':
+		return rune(c), nil
+	case 'b':
+		return '\b', nil
+	case 'f':
+		return '\f', nil
+	case 'n':
+		return '\n', nil
+	case 'r':
+		return '\r', nil
+	case 't':
+		return '\t', nil
+	case 'u':
+		r, err := d.readHex4()
+		if err != nil {
+			return 0, err
+		}
+		if !utf16.IsSurrogate(r) {
+			return r, nil
+		}
+
+		// A high surrogate should be followed by a low surrogate escape.
+		if d.pos+1 < len(d.data) && d.data[d.pos] == '\\' && d.data[d.pos+1] == 'u' {
+			save := d.pos
+			d.pos += 2
+			r2, err := d.readHex4()
+			if err != nil {
+				return 0, err
+			}
+			if dec := utf16.DecodeRune(r, r2); dec != utf8.RuneError {
+				return dec, nil
+			}
+			d.pos = save
+		}
+		return utf8.RuneError, nil
+	}
+
+	if d.json5 {
+		return d.readJSON5Escape(c)
+	}
+
+	d.pos--
+	return 0, d.errorf("invalid character %q in string escape code", c)
+}
+
+// readJSON5Escape handles the escapes which JSON5 allows in addition to the
+// JSON ones. The c is the character after the backslash, which has already
+// been read.
+func (d *decoder) readJSON5Escape(c byte) (rune, error) {
+	switch {
+	case c == '\'':
+		return '\'', nil
+	case c == 'v':
+		return '\v', nil
+	case c == '0' && !(d.peek() >= '0' && d.peek() <= '9'):
+		return 0, nil
+	case c == 'x':
+		if d.pos+2 > len(d.data) {
+			return 0, d.errorf("unexpected end of JSON input in string escape")
+		}
+		n, err := strconv.ParseUint(string(d.data[d.pos:d.pos+2]), 16, 8)
+		if err != nil {
+			return 0, d.errorf("invalid \\x escape %q", d.data[d.pos:d.pos+2])
+		}
+		d.pos += 2
+		return rune(n), nil
+	case c == '\r' || c == '\n':
+		if c == '\r' && d.peek() == '\n' {
+			d.pos++
+		}
+		d.line++
+		d.lineStart = d.pos
+		return -1, nil
+	case c >= '1' && c <= '9':
+		d.pos--
+		return 0, d.errorf("invalid character %q in string escape code", c)
+	}
+
+	// Any other character is escaped as itself.
+	d.pos--
+	r, size := utf8.DecodeRune(d.data[d.pos:])
+	d.pos += size
+	if r == '\u2028' || r == '\u2029' {
+		return -1, nil
+	}
+	return r, nil
+}
+
+func (d *decoder) readHex4() (rune, error) {
+	if d.pos+4 > len(d.data) {
+		return 0, d.errorf("unexpected end of JSON input in string escape")
+	}
+	n, err := strconv.ParseUint(string(d.data[d.pos:d.pos+4]), 16, 32)
+	if err != nil {
+		return 0, d.errorf("invalid \\u escape %q", d.data[d.pos:d.pos+4])
+	}
+	d.pos += 4
+	return rune(n), nil
+}
+
+func appendRune(b []byte, r rune) []byte {
+	var buf [utf8.UTFMax]byte
+	n := utf8.EncodeRune(buf[:], r)
+	return append(b, buf[:n]...)
+}
+
+func (d *decoder) unexpected() error {
+	r, _ := utf8.DecodeRune(d.data[d.pos:])
+	return d.errorf("invalid character %q", r)
+}
+
+func (d *decoder) expected(what string) error {
+	if d.pos >= len(d.data) {
+		return d.errorf("unexpected end of JSON input, expected %s", what)
+	}
+	r, _ := utf8.DecodeRune(d.data[d.pos:])
+	return d.errorf("invalid character %q, expected %s", r, what)
+}
+
+// errorf returns an error which includes the line and column of the current
+// position.
+func (d *decoder) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d, column %d: %s", d.line, d.pos-d.lineStart+1, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,148 @@
+package jsontidier
+
+import (
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecoderTokens(t *testing.T) {
+	dec := newDecoder([]byte(`{"aé😀\ud800": [1.5e3, true, null, "x\/y"], "b": {}}`))
+
+	expect := []json.Token{
+		json.Delim('{'),
+		"aé😀�",
+		json.Delim('['),
+		json.Number("1.5e3"),
+		true,
+		nil,
+		"x/y",
+		json.Delim(']'),
+		"b",
+		json.Delim('{'),
+		json.Delim('}'),
+		json.Delim('}'),
+	}
+	for _, e := range expect {
+		tok, err := dec.Token()
+		assert.Nil(t, err, "no error getting token")
+		assert.Equal(t, e, tok, "got expected token")
+	}
+
+	_, err := dec.Token()
+	assert.Equal(t, io.EOF, err, "got EOF at end of input")
+}
+
+func TestDecoderMatchesEncodingJSON(t *testing.T) {
+	inputs := []string{
+		`{}`,
+		`[]`,
+		`{"a":1,}`,
+		`[1,]`,
+		`[1 2]`,
+		`{"a" 1}`,
+		`{"a":01}`,
+		`{"a":-}`,
+		`{"a":1.}`,
+		`{"a":.5}`,
+		`{"a":1e}`,
+		`{"a":"\x"}`,
+		`{"a":"\u12"}`,
+		"{\"a\":\"\t\"}",
+		`{"a":tru}`,
+		`{"a":[}`,
+		`{"a":1`,
+		`{1:1}`,
+		`["é", -0.0e+0, false]`,
+	}
+
+	for _, input := range inputs {
+		dec := newDecoder([]byte(input))
+		var err error
+		for err == nil {
+			_, err = dec.Token()
+		}
+		assert.Equal(t, json.Valid([]byte(input)), err == io.EOF, "validity of %s", input)
+	}
+}
+
+func TestDecoderErrorPosition(t *testing.T) {
+	jt := NewJSONTidier(NewParams{})
+	_, err := jt.TidyString("{\n    \"a\": 1,\n    \"b\": x\n}")
+	assert.EqualError(t, err, `line 3, column 10: invalid character 'x'`, "error includes line and column")
+}
+
+func TestDecoderComments(t *testing.T) {
+	dec := newDecoder([]byte("{ // one\n  /* two\n */ \"a\": 1 /* three */ }"))
+	dec.comments = true
+
+	expect := []struct {
+		tok      json.Token
+		comments []comment
+	}{
+		{json.Delim('{'), nil},
+		{"a", []comment{{"// one", 0}, {"/* two\n */", 1}}},
+		{json.Number("1"), nil},
+		{json.Delim('}'), []comment{{"/* three */", 0}}},
+	}
+	for _, e := range expect {
+		tok, err := dec.Token()
+		assert.Nil(t, err, "no error getting token")
+		assert.Equal(t, e.tok, tok, "got expected token")
+		assert.Equal(t, e.comments, dec.tokComments, "got expected comments")
+	}
+	assert.Equal(t, 3, dec.line, "newlines in comments are counted")
+
+	for _, input := range []string{`{} /* open`, `{} / x`, `{} // ok`} {
+		dec := newDecoder([]byte(input))
+		dec.comments = true
+		var err error
+		for err == nil {
+			_, err = dec.Token()
+		}
+		assert.Equal(t, input == `{} // ok`, err == io.EOF, "validity of %s", input)
+	}
+}
+
+func TestDecoderJSON5(t *testing.T) {
+	dec := newDecoder([]byte("{a: 'it\\'s', $b_1: [0x1F, +.5, 5., -Infinity, NaN, 'x\\\ny\\x41\\0',], // c\n}"))
+	dec.json5 = true
+	dec.comments = true
+
+	expect := []json.Token{
+		json.Delim('{'),
+		"a",
+		"it's",
+		"$b_1",
+		json.Delim('['),
+		json.Number("31"),
+		json.Number("0.5"),
+		json.Number("5"),
+		json.Number("-Infinity"),
+		json.Number("NaN"),
+		"xyA\x00",
+		json.Delim(']'),
+		json.Delim('}'),
+	}
+	for _, e := range expect {
+		assert.True(t, dec.More() || e == json.Delim(']') || e == json.Delim('}'), "More before %v", e)
+		tok, err := dec.Token()
+		assert.Nil(t, err, "no error getting token")
+		assert.Equal(t, e, tok, "got expected token")
+	}
+
+	_, err := dec.Token()
+	assert.Equal(t, io.EOF, err, "got EOF at end of input")
+
+	for _, input := range []string{`[1,,]`, `[,]`, `{a:1,,}`, `[0x]`, `[.]`, `['\1']`, `{1a: 1}`, "['a\nb']"} {
+		dec := newDecoder([]byte(input))
+		dec.json5 = true
+		var err error
+		for err == nil {
+			_, err = dec.Token()
+		}
+		assert.NotEqual(t, io.EOF, err, "%s is not valid JSON5", input)
+	}
+}
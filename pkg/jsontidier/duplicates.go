@@ -0,0 +1,66 @@
+package jsontidier
+
+import (
+	"fmt"
+)
+
+// duplicates holds every value of a key which appeared more than once in an
+// object when DuplicateKeys is "keep-all", in the order they were found. The
+// key is only in the object's keyOrder once, so its members are always
+// written next to each other.
+type duplicates []interface{}
+
+// member is a single key and value in an object, as it is written out.
+type member struct {
+	key   string
+	value interface{}
+	// first and last say whether this is the first or last member written
+	// for its key. These are only both false for the middle values of a key
+	// with duplicates.
+	first bool
+	last  bool
+}
+
+// members returns the members of the object in order, with one member for
+// each value of a key with duplicates.
+func (jt *JSONTidier) members() []member {
+	ms := make([]member, 0, len(jt.keyOrder))
+	for _, k := range jt.keyOrder {
+		d, ok := jt.ourMap[k].(duplicates)
+		if !ok {
+			ms = append(ms, member{k, jt.ourMap[k], true, true})
+			continue
+		}
+		for i, v := range d {
+			ms = append(ms, member{k, v, i == 0, i == len(d)-1})
+		}
+	}
+	return ms
+}
+
+// keepsDuplicates returns true if the policy allows duplicate keys.
+func keepsDuplicates(policy string) bool {
+	return policy == "keep-first" || policy == "keep-last" || policy == "keep-all"
+}
+
+// addDuplicate handles a key which has already been seen in the object
+// according to the DuplicateKeys policy, and records a diagnostic for it.
+func (jt *JSONTidier) addDuplicate(dec *decoder, key string, value interface{}, line, col int) {
+	switch jt.duplicateKeys {
+	case "keep-last":
+		jt.ourMap[key] = value
+	case "keep-all":
+		d, ok := jt.ourMap[key].(duplicates)
+		if !ok {
+			d = duplicates{jt.ourMap[key]}
+		}
+		jt.ourMap[key] = append(d, value)
+	}
+
+	dec.diagnostics = append(dec.diagnostics, Diagnostic{
+		Line:    line,
+		Column:  col,
+		Path:    jt.currentPath(),
+		Message: fmt.Sprintf("duplicate key %q", key),
+	})
+}
@@ -0,0 +1,61 @@
+package jsontidier
+
+import (
+	"fmt"
+	"log"
+)
+
+// EmbeddedJSONRule says how to tidy a JSON document stored in a string value.
+// The document is tidied with Params, where "$" is the root of the embedded
+// document, and written back into the string. Output can be "compact", which
+// is the default, to write it on one line with no whitespace, or "pretty" to
+// write it the way Params say, without a final newline.
+type EmbeddedJSONRule struct {
+	Output string
+	Params NewParams
+}
+
+// embeddedRule returns the rule for the string at the current path, if there
+// is one.
+func (jt *JSONTidier) embeddedRule() (EmbeddedJSONRule, bool) {
+	path := jt.currentPath()
+	for re, rule := range jt.embedded {
+		if re.MatchString(path) {
+			if jt.debug {
+				log.Printf("Tidy embedded JSON at %s", path)
+			}
+			return rule, true
+		}
+	}
+	return EmbeddedJSONRule{}, false
+}
+
+// tidyEmbedded tidies the JSON document in the string s with rule.
+func tidyEmbedded(s string, rule EmbeddedJSONRule) (string, error) {
+	np := rule.Params
+	if rule.Output != "pretty" {
+		np.Minify = true
+	}
+	finalNewline := false
+	np.FinalNewline = &finalNewline
+	np.LineEnding = "lf"
+	np.BOM = "remove"
+	np.Stream = false
+
+	return NewJSONTidier(np).TidyString(s)
+}
+
+// embeddedValue returns the tidied JSON in a string value if the current
+// path has an embedded JSON rule. It returns false if there is no rule.
+func (jt *JSONTidier) embeddedValue(s string, dec *decoder) (string, bool, error) {
+	rule, ok := jt.embeddedRule()
+	if !ok {
+		return s, false, nil
+	}
+
+	tidied, err := tidyEmbedded(s, rule)
+	if err != nil {
+		return s, true, fmt.Errorf("line %d, column %d: could not tidy the JSON in the string at %s: %s", dec.tokLine, dec.tokCol, jt.currentPath(), err)
+	}
+	return tidied, true, nil
+}
@@ -19,21 +19,290 @@ type sortFunc func([]string, bool)
 // the JSONTidier type, has similar operations as the default map, but maintained
 // the keys order of inserted; similar to map, all single key operations (Get/Set/Delete) runs at O(1).
 type JSONTidier struct {
-	indent        string
-	ordering      map[*regexp.Regexp]sortFunc
-	sorting       []*regexp.Regexp
-	path          []string
-	ourMap        map[string]interface{}
-	keyOrder      []string
-	arrayReplacer *regexp.Regexp
-	debug         bool
+	indent          string
+	maxLineWidth    int
+	formats         map[*regexp.Regexp]string
+	escapeHTML      bool
+	stringEscapes   string
+	canonical       bool
+	minify          bool
+	lineEnding      string
+	bom             string
+	finalNewline    *bool
+	alignValues     bool
+	alignMaxPadding int
+	blankLines      bool
+	rawKeys         map[string]string
+	blankBefore     map[string]bool
+	numberPaths     []*regexp.Regexp
+	jsonc           bool
+	json5           bool
+	strictJSON      bool
+	trailingCommas  bool
+	duplicateKeys   string
+	embedded        map[*regexp.Regexp]EmbeddedJSONRule
+	stream          bool
+	diagnostics     []Diagnostic
+	comments        map[string]*comments
+	rootComments    comments
+	ordering        map[*regexp.Regexp]sortFunc
+	sorting         []arraySorter
+	path            []string
+	ourMap          map[string]interface{}
+	keyOrder        []string
+	rootValue       interface{}
+	rootIsValue     bool
+	arrayReplacer   *regexp.Regexp
+	arrayKeyOrder   map[*regexp.Regexp]string
+	defaultSorter   sortFunc
+	reordered       bool
+	debug           bool
 }
 
 type NewParams struct {
+	// Indent is the string used for each level of indentation. If this is
+	// "auto", the indentation is detected from each document, falling back
+	// to four spaces if the document has no indented lines.
 	Indent    *string
 	KeyOrder  map[string][]string
 	ArraySort []string
-	Debug     bool
+	// ArraySortRules are like ArraySort but allow each path to specify how
+	// the matching arrays are compared. These are checked after the paths in
+	// ArraySort.
+	ArraySortRules []ArraySortRule
+	// ArrayKeyOrder maps JSON Path expressions for arrays to either "first"
+	// or "union". The keys of every object in a matching array which does
+	// not match a KeyOrder path are reordered to match the first object in
+	// the array ("first"), or the order in which keys are first seen across
+	// all of the array's objects ("union"). Paths with any other value are
+	// ignored.
+	ArrayKeyOrder map[string]string
+	// SortKeys can be "alphabetical" or "natural". If it is set, the keys of
+	// every object which does not match a KeyOrder path are sorted in
+	// case-insensitive alphanumeric order, or in natural order where runs of
+	// digits are compared numerically so that "item2" sorts before "item10".
+	// Any other value is ignored.
+	SortKeys string
+	// MaxLineWidth is the longest line, in characters, that an array or
+	// object can be written on if it is written on a single line. If this is
+	// zero, every non-empty array and object is written with one element per
+	// line.
+	MaxLineWidth int
+	// Format maps JSON Path expressions to "inline", "expanded" or
+	// "compact". A matching array or object, along with everything inside
+	// it, is written on one line ("inline"), on one line without any spaces
+	// ("compact"), or with one element per line ("expanded"), regardless of
+	// MaxLineWidth. A path nested inside an "expanded" path can have its own
+	// format.
+	Format map[string]string
+	// EscapeHTML escapes "<", ">" and "&" in strings as "\u003c", "\u003e"
+	// and "\u0026". By default these are left as is.
+	EscapeHTML bool
+	// StringEscapes can be "minimal", "preserve" or "ascii". With "minimal",
+	// which is the default, strings are written with only the escapes that
+	// JSON requires. With "preserve", keys and strings are written exactly as
+	// they were in the original document. With "ascii", every non-ASCII
+	// character is escaped as well, so the output is 7-bit clean.
+	StringEscapes string
+	// Canonical makes the tidier produce output in the JSON Canonicalization
+	// Scheme from RFC 8785. The keys of every object are sorted by their
+	// UTF-16 code units, regardless of KeyOrder, numbers are written the way
+	// ECMAScript writes them, and there is no whitespace or final newline.
+	// Arrays are still sorted according to ArraySort.
+	Canonical bool
+	// Minify makes the tidier write its output on a single line with no
+	// whitespace, followed by a newline. Keys and arrays are still sorted, but
+	// Indent, MaxLineWidth and Format are ignored.
+	Minify bool
+	// LineEnding can be "lf", "crlf" or "auto". With "auto", which is the
+	// default, the output uses "\r\n" if the first line ending in the
+	// original document was "\r\n", and "\n" otherwise.
+	LineEnding string
+	// BOM can be "preserve", "add" or "remove". With "preserve", which is the
+	// default, the output starts with a UTF-8 byte order mark if the original
+	// document did.
+	BOM string
+	// FinalNewline says whether the output ends with a line ending. This
+	// defaults to true unless Canonical is set, except in TidyLines, where it
+	// always defaults to true. It does not apply to TidyMarkdown, which
+	// leaves the end of the document as it was.
+	FinalNewline *bool
+	// AlignValues pads the keys of objects which only contain scalar values
+	// so that all of the values line up in a column. This is skipped for any
+	// object where a key would need more than AlignValuesMaxPadding spaces of
+	// padding, unless AlignValuesMaxPadding is zero.
+	AlignValues           bool
+	AlignValuesMaxPadding int
+	// PreserveBlankLines keeps a single blank line wherever the original
+	// document had one or more blank lines between two members of an
+	// object. The blank line stays with the member that came after it, so it
+	// moves with that member when keys are reordered. A blank line is never
+	// written before the first member of an object. Objects with blank lines
+	// are always written with one member per line unless their Format says
+	// otherwise.
+	PreserveBlankLines bool
+	// NormalizeNumbers is a list of JSON Path expressions. Every number at or
+	// inside a matching path is rewritten in its shortest form without
+	// changing its value, so "1.50E+2" becomes "150" and "-0" becomes "0".
+	// Use "$" to normalize every number in the document.
+	NormalizeNumbers []string
+	// JSONC allows "//" and "/* */" comments in the document. Each comment is
+	// attached to the object member or array element it is next to, and
+	// stays with it when keys are reordered or arrays are sorted. Comments on
+	// the same line as a member go after it, and other comments go on their
+	// own lines before it. Arrays and objects containing comments are always
+	// written with one element per line. Comments are dropped if Canonical or
+	// Minify is set.
+	JSONC bool
+	// JSON5 makes the tidier accept JSON5, which allows comments, unquoted
+	// keys, single quoted strings, trailing commas, and more kinds of
+	// numbers. Keys, strings and numbers are written exactly as they were in
+	// the original document, and comments are kept as they are with JSONC.
+	JSON5 bool
+	// StrictJSON makes the tidier write strict JSON when JSONC or JSON5 is
+	// set. Comments are dropped, and keys, strings and numbers are converted
+	// to JSON. It is an error if the document contains Infinity or NaN.
+	StrictJSON bool
+	// AllowTrailingCommas makes the tidier accept a trailing comma after the
+	// last element of an array or object. These commas are removed from the
+	// output, and each one is reported by Diagnostics.
+	AllowTrailingCommas bool
+	// DuplicateKeys says what to do when a key appears more than once in the
+	// same object. With "error", which is the default, the document is
+	// rejected. With "keep-first" or "keep-last", only the first or last
+	// value is kept, in the position where the key first appeared. With
+	// "keep-all", every value is kept and they are written next to each
+	// other. Every duplicate is reported by Diagnostics unless the policy is
+	// "error". Any other value is treated as "error".
+	DuplicateKeys string
+	// EmbeddedJSON maps JSON Path expressions to rules for tidying string
+	// values which contain JSON documents. Each matching string is parsed as
+	// JSON, tidied with the rule's own Params, and written back into the
+	// string. It is an error if a matching string is not valid JSON.
+	EmbeddedJSON map[string]EmbeddedJSONRule
+	// Stream makes TidyBytes accept any number of JSON documents one after
+	// another, including JSON text sequences from RFC 7464 where each
+	// document starts with a record separator. Every document is tidied and
+	// each one after the first starts on a new line. Record separators are
+	// kept before the document they came before.
+	Stream bool
+	Debug  bool
+}
+
+// Diagnostic describes a problem in the original document which was fixed
+// by tidying it. The Path is the JSON Path of the array or object where the
+// problem was found, or of the member itself for a duplicate key.
+type Diagnostic struct {
+	Line    int
+	Column  int
+	Path    string
+	Message string
+}
+
+// rawString is a string value along with the exact text it had in the
+// original document, including its quotes.
+type rawString struct {
+	value string
+	raw   string
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (rs rawString) MarshalJSON() ([]byte, error) {
+	return appendString(nil, rs.value, true, false), nil
+}
+
+// rawNumber is a JSON5 number which is not valid JSON, like "0x1F" or
+// "+1", along with the equivalent JSON number. For Infinity and NaN, the
+// value is "Infinity", "-Infinity" or "NaN".
+type rawNumber struct {
+	value json.Number
+	raw   string
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (rn rawNumber) MarshalJSON() ([]byte, error) {
+	return json.Marshal(rn.value)
+}
+
+// numberValue returns the value of v if it is a number.
+func numberValue(v interface{}) (json.Number, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		return v, true
+	case rawNumber:
+		return v.value, true
+	}
+	return "", false
+}
+
+// stringValue returns the value of v if it is a string.
+func stringValue(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case rawString:
+		return v.value, true
+	}
+	return "", false
+}
+
+// ArraySortRule says how to sort the arrays matching Path. The Comparator
+// can be empty for the default sorting, "semver" to sort strings by semantic
+// version precedence, or "deep" to sort values of any type by a deep
+// comparison. Setting Order to "desc" sorts in descending order, which is the
+// exact reverse of the ascending order unless Stable is "original", in which
+// case elements that compare as equal keep their original relative order.
+type ArraySortRule struct {
+	Path       string
+	Comparator string
+	Order      string
+	Stable     string
+}
+
+// UnmarshalJSON accepts either a bare JSON Path string or an object with
+// "path", "comparator", "order" and "stable" keys.
+func (r *ArraySortRule) UnmarshalJSON(data []byte) error {
+	var path string
+	if err := json.Unmarshal(data, &path); err == nil {
+		*r = ArraySortRule{Path: path}
+		return nil
+	}
+
+	var rule struct {
+		Path       string
+		Comparator string
+		Order      string
+		Stable     string
+	}
+	if err := json.Unmarshal(data, &rule); err != nil {
+		return fmt.Errorf("an arraySort entry must be a string or an object: %s", err)
+	}
+	if rule.Path == "" {
+		return fmt.Errorf("an arraySort object must have a path")
+	}
+	switch rule.Comparator {
+	case "", "semver", "deep":
+	default:
+		return fmt.Errorf("unknown arraySort comparator for %s: %q", rule.Path, rule.Comparator)
+	}
+	switch rule.Order {
+	case "", "asc", "desc":
+	default:
+		return fmt.Errorf(`the arraySort order for %s must be "asc" or "desc", not %q`, rule.Path, rule.Order)
+	}
+	switch rule.Stable {
+	case "", "original":
+	default:
+		return fmt.Errorf(`the arraySort stable value for %s must be "original", not %q`, rule.Path, rule.Stable)
+	}
+
+	*r = ArraySortRule(rule)
+	return nil
+}
+
+type arraySorter struct {
+	re   *regexp.Regexp
+	rule ArraySortRule
 }
 
 // Create a new JSONTidier
@@ -42,15 +311,67 @@ func NewJSONTidier(np NewParams) *JSONTidier {
 	for k, v := range np.KeyOrder {
 		o[pathToRegexp(k, np.Debug)] = makeKeySorter(v)
 	}
+	ako := make(map[*regexp.Regexp]string)
+	for k, v := range np.ArrayKeyOrder {
+		if v != "first" && v != "union" {
+			if np.Debug {
+				log.Printf("Ignoring unknown arrayKeyOrder mode %q for %s", v, k)
+			}
+			continue
+		}
+		ako[pathToRegexp(k, np.Debug)] = v
+	}
+	f := make(map[*regexp.Regexp]string)
+	for k, v := range np.Format {
+		f[pathToRegexp(k, np.Debug)] = v
+	}
+	var n []*regexp.Regexp
+	for _, path := range np.NormalizeNumbers {
+		n = append(n, pathToRegexp(path, np.Debug))
+	}
+	e := make(map[*regexp.Regexp]EmbeddedJSONRule)
+	for k, v := range np.EmbeddedJSON {
+		e[pathToRegexp(k, np.Debug)] = v
+	}
 
 	jt := &JSONTidier{
-		ordering:      o,
-		sorting:       pathsToRegexps(np.ArraySort, np.Debug),
-		path:          []string{},
-		ourMap:        make(map[string]interface{}),
-		keyOrder:      []string{},
-		arrayReplacer: regexp.MustCompile(`(?:\[\d+\])*$`),
-		debug:         np.Debug,
+		ordering:        o,
+		sorting:         makeArraySorters(np.ArraySort, np.ArraySortRules, np.Debug),
+		path:            []string{},
+		ourMap:          make(map[string]interface{}),
+		keyOrder:        []string{},
+		arrayReplacer:   regexp.MustCompile(`(?:\[\d+\])*$`),
+		arrayKeyOrder:   ako,
+		maxLineWidth:    np.MaxLineWidth,
+		formats:         f,
+		escapeHTML:      np.EscapeHTML,
+		stringEscapes:   np.StringEscapes,
+		canonical:       np.Canonical,
+		minify:          np.Minify,
+		lineEnding:      np.LineEnding,
+		bom:             np.BOM,
+		finalNewline:    np.FinalNewline,
+		alignValues:     np.AlignValues,
+		alignMaxPadding: np.AlignValuesMaxPadding,
+		blankLines:      np.PreserveBlankLines,
+		rawKeys:         make(map[string]string),
+		blankBefore:     make(map[string]bool),
+		numberPaths:     n,
+		jsonc:           np.JSONC,
+		json5:           np.JSON5,
+		strictJSON:      np.StrictJSON,
+		trailingCommas:  np.AllowTrailingCommas,
+		duplicateKeys:   np.DuplicateKeys,
+		embedded:        e,
+		stream:          np.Stream,
+		comments:        make(map[string]*comments),
+		debug:           np.Debug,
+	}
+	switch np.SortKeys {
+	case "alphabetical":
+		jt.defaultSorter = makeKeySorter(nil)
+	case "natural":
+		jt.defaultSorter = naturalKeySorter
 	}
 	if np.Indent == nil {
 		jt.indent = "    "
@@ -62,17 +383,20 @@ func NewJSONTidier(np NewParams) *JSONTidier {
 	return jt
 }
 
-// All of this regexp stuff is really gross. It'd be much better to have a
-// real JSON Path implementation that could say if two paths match.
-func pathsToRegexps(paths []string, debug bool) []*regexp.Regexp {
-	var r []*regexp.Regexp
+func makeArraySorters(paths []string, rules []ArraySortRule, debug bool) []arraySorter {
+	var s []arraySorter
 	for _, path := range paths {
-		r = append(r, pathToRegexp(path, debug))
+		s = append(s, arraySorter{pathToRegexp(path, debug), ArraySortRule{Path: path}})
+	}
+	for _, rule := range rules {
+		s = append(s, arraySorter{pathToRegexp(rule.Path, debug), rule})
 	}
 
-	return r
+	return s
 }
 
+// All of this regexp stuff is really gross. It'd be much better to have a
+// real JSON Path implementation that could say if two paths match.
 func pathToRegexp(path string, debug bool) *regexp.Regexp {
 	var parts []string
 	pieces := strings.Split(path, ".")
@@ -115,16 +439,16 @@ func pieceRegexp(piece string) string {
 		singleQuote = regexp.MustCompile(`'`)
 	}
 
-	if piece == "$" {
-		return `^\$`
-	}
-
 	var arrayRe = ""
 	if isArray.MatchString(piece) {
 		piece = isArray.ReplaceAllLiteralString(piece, "")
 		arrayRe = `\[\d+\]`
 	}
 
+	if piece == "$" {
+		return `^\$` + arrayRe
+	}
+
 	piece = singleQuote.ReplaceAllLiteralString(piece, `\'`)
 
 	return regexp.QuoteMeta(fmt.Sprintf("['%s']", piece)) + arrayRe
@@ -173,69 +497,296 @@ func makeKeySorter(order []string) sortFunc {
 	}
 }
 
+func naturalKeySorter(arr []string, debug bool) {
+	var msg string
+	if debug {
+		msg = fmt.Sprintf("Naturally sorted\n    keys    = %v", arr)
+	}
+
+	sort.SliceStable(arr, func(i, j int) bool {
+		return compareNatural(arr[i], arr[j]) < 0
+	})
+
+	if debug {
+		log.Printf("%s\n    new     = %v", msg, arr)
+	}
+}
+
 func (jt *JSONTidier) TidyString(orig string) (string, error) {
 	tidied, err := jt.TidyBytes([]byte(orig))
 	return string(tidied), err
 }
 
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
 func (jt *JSONTidier) TidyBytes(orig []byte) ([]byte, error) {
-	err := json.Unmarshal(orig, jt)
+	hasBOM := bytes.HasPrefix(orig, utf8BOM)
+	data := bytes.TrimPrefix(orig, utf8BOM)
+
+	var tidied []byte
+	var err error
+	if jt.stream {
+		tidied, err = jt.tidyStream(data, orig)
+	} else {
+		err = jt.UnmarshalJSON(data)
+		if err == nil {
+			tidied, err = jt.render(orig)
+		}
+	}
 	if err != nil {
 		return []byte{}, err
 	}
 
-	tidied, err := json.MarshalIndent(jt, "", jt.indent)
-	if err != nil {
-		return []byte{}, err
+	finalNewline := !jt.canonical
+	if jt.finalNewline != nil {
+		finalNewline = *jt.finalNewline
+	}
+	if finalNewline && len(tidied) > 0 {
+		tidied = append(tidied, '\n')
 	}
 
-	tidied = append(tidied, '\n')
+	if jt.lineEnding == "crlf" || (jt.lineEnding != "lf" && usesCRLF(orig)) {
+		tidied = bytes.ReplaceAll(tidied, []byte("\n"), []byte("\r\n"))
+	}
+
+	if jt.bom == "add" || (jt.bom != "remove" && hasBOM) {
+		tidied = append(append([]byte{}, utf8BOM...), tidied...)
+	}
 
 	return tidied, nil
 }
 
+// render writes the document which was just parsed. The orig is the whole
+// original input, which is used to detect the indentation.
+func (jt *JSONTidier) render(orig []byte) ([]byte, error) {
+	if jt.canonical {
+		return appendCanonical(nil, jt.root())
+	}
+
+	p := jt.newPrinter()
+	if jt.indent == "auto" {
+		p.indent = jt.detectIndent(orig)
+	}
+	return p.print(jt.root(), &jt.rootComments)
+}
+
+// tidyStream tidies every document in data, which may contain any number of
+// JSON values one after another. Each document after the first starts on a
+// new line. Any record separators from RFC 7464 before a document are kept
+// before it.
+func (jt *JSONTidier) tidyStream(data, orig []byte) ([]byte, error) {
+	dec := jt.newDecoder(data, 1)
+	dec.stream = true
+	defer func() {
+		jt.diagnostics = dec.diagnostics
+	}()
+
+	var out []byte
+	end := 0
+	for n := 0; ; n++ {
+		t, err := dec.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if n > 0 {
+			out = append(out, '\n')
+		}
+		rs := bytes.Count(data[end:dec.tokPos], []byte{recordSeparator})
+		out = append(out, bytes.Repeat([]byte{recordSeparator}, rs)...)
+
+		err = jt.parseDocument(dec, t)
+		if err != nil {
+			return nil, err
+		}
+		doc, err := jt.render(orig)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, doc...)
+		end = dec.pos
+	}
+
+	if !jt.canonical && !jt.minify && !jt.strictJSON {
+		for _, text := range commentTexts(dec.tokComments) {
+			out = append(out, '\n')
+			out = append(out, text...)
+		}
+	}
+
+	return out, nil
+}
+
+// detectIndent returns the indentation used in b. Only lines which start
+// with a JSON token are looked at, so comments and multi-line strings are
+// ignored. If any of these lines is indented with a tab this is a tab.
+// Otherwise it is the smallest number of spaces that any of them is indented
+// with.
+func (jt *JSONTidier) detectIndent(b []byte) string {
+	dec := jt.newDecoder(bytes.TrimPrefix(b, utf8BOM), 1)
+	dec.stream = jt.stream
+	for {
+		if _, err := dec.Token(); err != nil {
+			break
+		}
+	}
+
+	spaces := 0
+	for _, indent := range dec.indents {
+		if strings.HasPrefix(indent, "\t") {
+			return "\t"
+		}
+
+		n := len(indent) - len(strings.TrimLeft(indent, " "))
+		if n > 0 && (spaces == 0 || n < spaces) {
+			spaces = n
+		}
+	}
+
+	if spaces == 0 {
+		return "    "
+	}
+	return strings.Repeat(" ", spaces)
+}
+
+// usesCRLF returns true if the first line ending in b is "\r\n".
+func usesCRLF(b []byte) bool {
+	i := bytes.IndexByte(b, '\n')
+	return i > 0 && b[i-1] == '\r'
+}
+
 // this implements type json.Unmarshaler interface, so can be called in json.Unmarshal(data, om)
+//
+// The document can have any JSON value at its root. If it is not an object
+// then the JSONTidier holds that value instead of any keys.
 func (jt *JSONTidier) UnmarshalJSON(data []byte) error {
-	dec := json.NewDecoder(bytes.NewReader(data))
-	dec.UseNumber()
+	return jt.unmarshal(data, 1)
+}
+
+// unmarshal parses data, which starts on the given line of its input.
+func (jt *JSONTidier) unmarshal(data []byte, line int) error {
+	dec := jt.newDecoder(data, line)
+	defer func() {
+		jt.diagnostics = dec.diagnostics
+	}()
 
-	// must open with a delim token '{'
 	t, err := dec.Token()
-	if err != nil {
+	if err == io.EOF {
+		return fmt.Errorf("unexpected end of JSON input")
+	} else if err != nil {
 		return err
 	}
-	if delim, ok := t.(json.Delim); !ok || delim != '{' {
-		return fmt.Errorf("expect JSON object open with '{'")
-	}
-
-	err = jt.parseObject(dec)
+	err = jt.parseDocument(dec, t)
 	if err != nil {
 		return err
 	}
 
 	t, err = dec.Token()
-	if err != io.EOF {
-		return fmt.Errorf("expect end of JSON object but got more token: %T: %v or err: %v", t, t, err)
+	if err == nil {
+		return fmt.Errorf("line %d, column %d: expect end of JSON input but got more token: %T: %v", dec.tokLine, dec.tokCol, t, t)
+	} else if err != io.EOF {
+		return err
 	}
+	jt.rootComments.after = commentTexts(dec.tokComments)
 
 	return nil
 }
 
-func (jt *JSONTidier) parseObject(dec *json.Decoder) (err error) {
+// newDecoder returns a decoder for data, which starts on the given line of
+// its input.
+func (jt *JSONTidier) newDecoder(data []byte, line int) *decoder {
+	dec := newDecoder(data)
+	dec.line = line
+	dec.comments = jt.jsonc || jt.json5
+	dec.json5 = jt.json5
+	dec.trailingCommas = jt.trailingCommas
+	return dec
+}
+
+// parseDocument parses a whole document, starting with the token t, and
+// makes it the document held by jt.
+func (jt *JSONTidier) parseDocument(dec *decoder, t json.Token) (err error) {
+	jt.path = []string{"$"}
+	jt.ourMap = make(map[string]interface{})
+	jt.keyOrder = []string{}
+	jt.rawKeys = make(map[string]string)
+	jt.blankBefore = make(map[string]bool)
+	jt.comments = make(map[string]*comments)
+	jt.rootComments = comments{}
+	jt.rootValue = nil
+	jt.rootIsValue = false
+	jt.reordered = false
+
+	jt.rootComments.before = commentTexts(dec.tokComments)
+	if delim, ok := t.(json.Delim); ok && delim == '{' {
+		jt.rootComments.end, err = jt.parseObject(dec)
+	} else {
+		jt.rootValue, jt.rootComments.end, err = jt.handleDelim(t, dec)
+		jt.rootIsValue = true
+	}
+
+	return err
+}
+
+// root returns the value at the root of the document, which is jt itself
+// unless the document is not an object.
+func (jt *JSONTidier) root() interface{} {
+	if jt.rootIsValue {
+		return jt.rootValue
+	}
+	return jt
+}
+
+// memberComments returns the comments for the member with the given key,
+// creating them if necessary.
+func (jt *JSONTidier) memberComments(key string) *comments {
+	c, ok := jt.comments[key]
+	if !ok {
+		c = &comments{}
+		jt.comments[key] = c
+	}
+	return c
+}
+
+// parseObject parses the members of an object and its closing brace. It
+// returns any comments found before the closing brace which are not on the
+// same line as the last member.
+func (jt *JSONTidier) parseObject(dec *decoder) (end []string, err error) {
 	if jt.debug {
 		log.Printf("Parse object at %s", jt.currentPath())
 	}
 
 	var t json.Token
+	var prev string
 	for dec.More() {
 		t, err = dec.Token()
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		key, ok := t.(string)
 		if !ok {
-			return fmt.Errorf("expecting JSON key should be always a string: %T: %v", t, t)
+			return nil, fmt.Errorf("expecting JSON key should be always a string: %T: %v", t, t)
+		}
+		sameLine, before := splitComments(dec.tokComments)
+		if len(jt.keyOrder) == 0 {
+			before = append(sameLine, before...)
+		} else if len(sameLine) > 0 {
+			c := jt.memberComments(prev)
+			c.after = append(c.after, sameLine...)
+		}
+		_, duplicate := jt.ourMap[key]
+		keyLine, keyCol := dec.tokLine, dec.tokCol
+		if duplicate && !keepsDuplicates(jt.duplicateKeys) {
+			return nil, fmt.Errorf("line %d, column %d: duplicate key %q in %s", keyLine, keyCol, key, jt.currentPath())
+		}
+		if _, ok := jt.rawKeys[key]; !ok && jt.keepRaw(dec.raw) {
+			jt.rawKeys[key] = string(dec.raw)
+		}
+		if jt.blankLines && dec.tokBlankLine && len(jt.keyOrder) > 0 && !duplicate {
+			jt.blankBefore[key] = true
 		}
 
 		jt.pushPath(fmt.Sprintf(`['%s']`, key))
@@ -244,32 +795,55 @@ func (jt *JSONTidier) parseObject(dec *json.Decoder) (err error) {
 		if err == io.EOF {
 			break
 		} else if err != nil {
-			return err
+			return nil, err
+		}
+		before = append(before, commentTexts(dec.tokComments)...)
+		if len(before) > 0 {
+			c := jt.memberComments(key)
+			c.before = append(c.before, before...)
 		}
 
 		var value interface{}
-		value, err = jt.handleDelim(t, dec)
+		var valueEnd []string
+		value, valueEnd, err = jt.handleDelim(t, dec)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if len(valueEnd) > 0 {
+			jt.memberComments(key).end = valueEnd
 		}
 
-		jt.keyOrder = append(jt.keyOrder, key)
-		jt.ourMap[key] = value
+		if duplicate {
+			jt.addDuplicate(dec, key, value, keyLine, keyCol)
+		} else {
+			jt.keyOrder = append(jt.keyOrder, key)
+			jt.ourMap[key] = value
+		}
+		prev = key
 
 		jt.popPath()
 	}
 
+	jt.checkTrailingComma(dec)
+
 	t, err = dec.Token()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if delim, ok := t.(json.Delim); !ok || delim != '}' {
-		return fmt.Errorf("expect JSON object close with '}'")
+		return nil, fmt.Errorf("expect JSON object close with '}'")
+	}
+	sameLine, end := splitComments(dec.tokComments)
+	if len(jt.keyOrder) == 0 {
+		end = append(sameLine, end...)
+	} else if len(sameLine) > 0 {
+		c := jt.memberComments(prev)
+		c.after = append(c.after, sameLine...)
 	}
 
 	jt.maybeReorder()
 
-	return nil
+	return end, nil
 }
 
 func (jt *JSONTidier) pushPath(p string) {
@@ -291,16 +865,25 @@ func (jt *JSONTidier) maybeReorder() {
 
 		if match {
 			s(jt.keyOrder, jt.debug)
-			break
+			jt.reordered = true
+			return
 		}
 	}
+
+	if jt.defaultSorter != nil {
+		jt.defaultSorter(jt.keyOrder, jt.debug)
+		jt.reordered = true
+	}
 }
 
 func (jt *JSONTidier) currentPath() string {
 	return strings.Join(jt.path, "")
 }
 
-func (jt *JSONTidier) handleDelim(t json.Token, dec *json.Decoder) (res interface{}, err error) {
+// handleDelim returns the value starting with the token t. If this is an
+// array or object, it also returns the comments found before its closing
+// bracket.
+func (jt *JSONTidier) handleDelim(t json.Token, dec *decoder) (res interface{}, end []string, err error) {
 	if delim, ok := t.(json.Delim); ok {
 		switch delim {
 		case '{':
@@ -308,34 +891,139 @@ func (jt *JSONTidier) handleDelim(t json.Token, dec *json.Decoder) (res interfac
 			jt2.debug = jt.debug
 			jt2.ordering = jt.ordering
 			jt2.sorting = jt.sorting
+			jt2.arrayKeyOrder = jt.arrayKeyOrder
+			jt2.defaultSorter = jt.defaultSorter
+			jt2.stringEscapes = jt.stringEscapes
+			jt2.json5 = jt.json5
+			jt2.strictJSON = jt.strictJSON
+			jt2.duplicateKeys = jt.duplicateKeys
+			jt2.embedded = jt.embedded
+			jt2.blankLines = jt.blankLines
+			jt2.numberPaths = jt.numberPaths
 			jt2.path = make([]string, len(jt.path))
 			for i, p := range jt.path {
 				jt2.path[i] = p
 			}
-			err = jt2.parseObject(dec)
+			end, err = jt2.parseObject(dec)
 			if err != nil {
 				return
 			}
-			return jt2, nil
+			return jt2, end, nil
 		case '[':
 			var value []interface{}
-			value, err = jt.parseArray(dec)
+			value, end, err = jt.parseArray(dec)
 			if err != nil {
 				return
 			}
-			return value, nil
+			return value, end, nil
 		default:
-			return nil, fmt.Errorf("Unexpected delimiter: %q", delim)
+			return nil, nil, fmt.Errorf("Unexpected delimiter: %q", delim)
+		}
+	}
+	if str, ok := t.(string); ok {
+		if tidied, ok, err := jt.embeddedValue(str, dec); ok {
+			return tidied, nil, err
+		}
+		if jt.keepRaw(dec.raw) {
+			return rawString{str, string(dec.raw)}, nil, nil
 		}
 	}
-	return t, nil
+	if n, ok := t.(json.Number); ok {
+		return jt.number(n, dec)
+	}
+	return t, nil, nil
+}
+
+// checkTrailingComma records a diagnostic if the decoder just skipped a
+// trailing comma at the end of the current array or object. These are
+// allowed in JSON5, so they are not reported for it.
+func (jt *JSONTidier) checkTrailingComma(dec *decoder) {
+	if dec.commaLine == 0 || jt.json5 {
+		return
+	}
+
+	dec.diagnostics = append(dec.diagnostics, Diagnostic{
+		Line:    dec.commaLine,
+		Column:  dec.commaCol,
+		Path:    jt.currentPath(),
+		Message: "trailing comma",
+	})
+}
+
+// Diagnostics returns the problems which were fixed in the document passed
+// to the last call to TidyBytes, TidyString or UnmarshalJSON.
+func (jt *JSONTidier) Diagnostics() []Diagnostic {
+	return jt.diagnostics
+}
+
+// keepRaw returns true if a key or string should be written exactly as it
+// was in the original document. This is always the case for JSON5 unless
+// strict JSON output was requested, in which case only keys and strings which
+// are valid JSON are kept.
+func (jt *JSONTidier) keepRaw(raw []byte) bool {
+	if jt.json5 && !jt.strictJSON {
+		return true
+	}
+	return jt.stringEscapes == "preserve" && (!jt.json5 || json.Valid(raw))
 }
 
-func (jt *JSONTidier) parseArray(dec *json.Decoder) (arr []interface{}, err error) {
+func (jt *JSONTidier) number(n json.Number, dec *decoder) (interface{}, []string, error) {
+	raw := string(dec.raw)
+	if n == "NaN" || strings.HasSuffix(string(n), "Infinity") {
+		if jt.strictJSON {
+			return nil, nil, fmt.Errorf("line %d, column %d: %s cannot be written as strict JSON", dec.tokLine, dec.tokCol, raw)
+		}
+		return rawNumber{n, raw}, nil, nil
+	}
+	if jt.normalizeNumbers() {
+		return normalizeNumber(n), nil, nil
+	}
+	if raw != string(n) && !jt.strictJSON {
+		return rawNumber{n, raw}, nil, nil
+	}
+	return n, nil, nil
+}
+
+// normalizeNumbers returns true if the current path or any of its ancestors
+// matches one of the NormalizeNumbers paths.
+func (jt *JSONTidier) normalizeNumbers() bool {
+	for i := len(jt.path); i > 0; i-- {
+		cur := strings.Join(jt.path[:i], "")
+		for _, re := range jt.numberPaths {
+			match := re.MatchString(cur)
+
+			if jt.debug {
+				log.Printf("Normalize numbers? %s =~ %s = %v", cur, re.String(), match)
+			}
+
+			if match {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// parseArray parses the elements of an array and its closing bracket. It
+// also returns any comments found before the closing bracket which are not on
+// the same line as the last element. Elements with comments attached to them
+// are stored as commented values.
+func (jt *JSONTidier) parseArray(dec *decoder) (arr []interface{}, end []string, err error) {
 	if jt.debug {
 		log.Printf("Parse array at %s", jt.currentPath())
 	}
 
+	elems := make(map[int]*comments)
+	elemComments := func(i int) *comments {
+		c, ok := elems[i]
+		if !ok {
+			c = &comments{}
+			elems[i] = c
+		}
+		return c
+	}
+
 	var t json.Token
 	arr = make([]interface{}, 0)
 	i := 0
@@ -344,19 +1032,35 @@ func (jt *JSONTidier) parseArray(dec *json.Decoder) (arr []interface{}, err erro
 		if err != nil {
 			return
 		}
+		sameLine, before := splitComments(dec.tokComments)
+		if i == 0 {
+			before = append(sameLine, before...)
+		} else if len(sameLine) > 0 {
+			c := elemComments(i - 1)
+			c.after = append(c.after, sameLine...)
+		}
+		if len(before) > 0 {
+			elemComments(i).before = before
+		}
 
 		jt.pushPath(fmt.Sprintf("[%d]", i))
-		i++
 
 		var value interface{}
-		value, err = jt.handleDelim(t, dec)
+		var valueEnd []string
+		value, valueEnd, err = jt.handleDelim(t, dec)
 		if err != nil {
 			return
 		}
+		if len(valueEnd) > 0 {
+			elemComments(i).end = valueEnd
+		}
 		arr = append(arr, value)
+		i++
 
 		jt.popPath()
 	}
+	jt.checkTrailingComma(dec)
+
 	t, err = dec.Token()
 	if err != nil {
 		return
@@ -365,61 +1069,212 @@ func (jt *JSONTidier) parseArray(dec *json.Decoder) (arr []interface{}, err erro
 		err = fmt.Errorf("expect JSON array close with ']'")
 		return
 	}
+	sameLine, end := splitComments(dec.tokComments)
+	if i == 0 {
+		end = append(sameLine, end...)
+	} else if len(sameLine) > 0 {
+		c := elemComments(i - 1)
+		c.after = append(c.after, sameLine...)
+	}
+
+	for i, c := range elems {
+		arr[i] = commented{arr[i], c}
+	}
 
-	if jt.shouldSortArray() {
-		jt.sortArray(arr)
+	if mode, ok := jt.arrayKeyOrderMode(); ok {
+		alignArrayKeys(arr, mode, jt.debug)
+	}
+
+	if rule, ok := jt.arraySortRule(); ok {
+		jt.sortArray(arr, rule)
 	}
 
 	return
 }
 
-func (jt *JSONTidier) shouldSortArray() bool {
+func (jt *JSONTidier) arrayKeyOrderMode() (string, bool) {
 	cur := jt.currentPath()
-	for _, re := range jt.sorting {
+	for re, mode := range jt.arrayKeyOrder {
 		match := re.MatchString(cur)
 
 		if jt.debug {
-			log.Printf("Sort array?    %s =~ %s = %v", cur, re.String(), match)
+			log.Printf("Align keys?    %s =~ %s = %v", cur, re.String(), match)
 		}
 
 		if match {
-			return true
+			return mode, true
 		}
 	}
 
-	return false
+	return "", false
 }
 
-func (jt *JSONTidier) sortArray(arr []interface{}) {
-	if _, ok := arr[0].(json.Number); ok {
-		sort.SliceStable(arr, func(i, j int) bool {
-			a := arr[i].(json.Number)
-			b := arr[j].(json.Number)
-			return a < b
+// alignArrayKeys reorders the keys of each object in the array so that they
+// all share the same order. Objects which were already reordered by a
+// keyOrder path are left alone. Keys which are not in the reference order
+// are moved to the end, keeping their existing relative order.
+func alignArrayKeys(arr []interface{}, mode string, debug bool) {
+	weights := make(map[string]int)
+	for _, v := range arr {
+		o, ok := uncommented(v).(*JSONTidier)
+		if !ok {
+			continue
+		}
+		for _, k := range o.keyOrder {
+			if _, exists := weights[k]; !exists {
+				weights[k] = len(weights)
+			}
+		}
+		if mode != "union" {
+			break
+		}
+	}
+
+	for _, v := range arr {
+		o, ok := uncommented(v).(*JSONTidier)
+		if !ok || o.reordered {
+			continue
+		}
+
+		if debug {
+			log.Printf("Aligning keys %v", o.keyOrder)
+		}
+
+		sort.SliceStable(o.keyOrder, func(i, j int) bool {
+			aw, exists := weights[o.keyOrder[i]]
+			if !exists {
+				aw = len(weights)
+			}
+			bw, exists := weights[o.keyOrder[j]]
+			if !exists {
+				bw = len(weights)
+			}
+			return aw < bw
 		})
-	} else if _, ok := arr[0].(string); ok {
-		sort.SliceStable(arr, func(i, j int) bool {
-			a := arr[i].(string)
-			b := arr[j].(string)
-			return strings.ToLower(a) < strings.ToLower(b)
+	}
+}
+
+func (jt *JSONTidier) arraySortRule() (ArraySortRule, bool) {
+	cur := jt.currentPath()
+	for _, s := range jt.sorting {
+		match := s.re.MatchString(cur)
+
+		if jt.debug {
+			log.Printf("Sort array?    %s =~ %s = %v", cur, s.re.String(), match)
+		}
+
+		if match {
+			return s.rule, true
+		}
+	}
+
+	return ArraySortRule{}, false
+}
+
+func (jt *JSONTidier) sortArray(arr []interface{}, rule ArraySortRule) {
+	if len(arr) == 0 {
+		return
+	}
+
+	// The elements are compared without their comments, and the comments
+	// move along with them.
+	values := make([]interface{}, len(arr))
+	for i, v := range arr {
+		values[i] = uncommented(v)
+	}
+
+	var less func(a, b interface{}) bool
+	switch rule.Comparator {
+	case "semver":
+		less = semverLess(values)
+	case "deep":
+		less = func(a, b interface{}) bool {
+			return compareValues(a, b) < 0
+		}
+	default:
+		less = defaultLess(values)
+	}
+	if less == nil {
+		return
+	}
+
+	order := make([]int, len(arr))
+	for i := range order {
+		order[i] = i
+	}
+	if rule.Order != "desc" {
+		sort.SliceStable(order, func(i, j int) bool {
+			return less(values[order[i]], values[order[j]])
+		})
+	} else if rule.Stable == "original" {
+		sort.SliceStable(order, func(i, j int) bool {
+			return less(values[order[j]], values[order[i]])
+		})
+	} else {
+		sort.SliceStable(order, func(i, j int) bool {
+			return less(values[order[i]], values[order[j]])
 		})
+		for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+			order[i], order[j] = order[j], order[i]
+		}
+	}
+
+	sorted := make([]interface{}, len(arr))
+	for i, o := range order {
+		sorted[i] = arr[o]
 	}
+	copy(arr, sorted)
 
 	return
 }
 
+// defaultLess returns a function for sorting an array of numbers or an array
+// of strings. If the array contains anything else it returns nil.
+func defaultLess(arr []interface{}) func(a, b interface{}) bool {
+	allOf := func(check func(interface{}) bool) bool {
+		for _, v := range arr {
+			if !check(v) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if allOf(func(v interface{}) bool { _, ok := numberValue(v); return ok }) {
+		return func(a, b interface{}) bool {
+			an, _ := numberValue(a)
+			bn, _ := numberValue(b)
+			return compareNumbers(an, bn) < 0
+		}
+	} else if allOf(func(v interface{}) bool { _, ok := stringValue(v); return ok }) {
+		return func(a, b interface{}) bool {
+			as, _ := stringValue(a)
+			bs, _ := stringValue(b)
+			return strings.ToLower(as) < strings.ToLower(bs)
+		}
+	}
+
+	return nil
+}
+
 // this implements type json.Marshaler interface, so can be called in json.Marshal(om)
 func (jt *JSONTidier) MarshalJSON() ([]byte, error) {
+	if jt.rootIsValue {
+		return json.Marshal(jt.rootValue)
+	}
+
 	res := []byte{'{'}
-	for i, k := range jt.keyOrder {
-		res = append(res, fmt.Sprintf("%q:", k)...)
+	members := jt.members()
+	for i, m := range members {
+		res = appendString(res, m.key, true, false)
+		res = append(res, ':')
 
-		b, err := json.Marshal(jt.ourMap[k])
+		b, err := json.Marshal(m.value)
 		if err != nil {
 			return nil, err
 		}
 		res = append(res, b...)
-		if i != len(jt.keyOrder)-1 {
+		if i != len(members)-1 {
 			res = append(res, ',')
 		}
 	}
@@ -1,6 +1,7 @@
 package jsontidier
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -440,9 +441,583 @@ func stringRef(s string) *string {
 	return &s
 }
 
+func boolRef(b bool) *bool {
+	return &b
+}
+
 func compareTidied(t *testing.T, np NewParams, orig, expect string) {
 	jt := NewJSONTidier(np)
 	tidied, err := jt.TidyString(orig)
 	assert.Nil(t, err, "no error calling TidyString")
 	assert.Equal(t, expect, tidied, "got expected tidied JSON")
 }
+
+func TestArraySortRuleUnmarshal(t *testing.T) {
+	var rules []ArraySortRule
+	err := json.Unmarshal([]byte(`["$..required", {"path": "$.versions", "comparator": "semver"}]`), &rules)
+	assert.Nil(t, err, "no error unmarshaling rules")
+	assert.Equal(
+		t,
+		[]ArraySortRule{{Path: "$..required"}, {Path: "$.versions", Comparator: "semver"}},
+		rules,
+		"got expected rules",
+	)
+
+	err = json.Unmarshal([]byte(`[{"path": "$.versions", "comparator": "bogus"}]`), &rules)
+	assert.NotNil(t, err, "error for unknown comparator")
+}
+
+func TestArrayKeyOrder(t *testing.T) {
+	orig := `{
+"first": [ { "id": 1, "name": "a" }, { "name": "b", "extra": true, "id": 2 }, 42 ],
+"union": [ { "id": 1, "name": "a" }, { "name": "b", "extra": true, "id": 2 }, { "extra": false, "id": 3 } ],
+"unknown": [ { "id": 1, "name": "a" }, { "name": "b", "id": 2 } ]
+}`
+
+	expect := `{
+    "first": [
+        {
+            "id": 1,
+            "name": "a"
+        },
+        {
+            "id": 2,
+            "name": "b",
+            "extra": true
+        },
+        42
+    ],
+    "union": [
+        {
+            "id": 1,
+            "name": "a"
+        },
+        {
+            "id": 2,
+            "name": "b",
+            "extra": true
+        },
+        {
+            "id": 3,
+            "extra": false
+        }
+    ],
+    "unknown": [
+        {
+            "id": 1,
+            "name": "a"
+        },
+        {
+            "name": "b",
+            "id": 2
+        }
+    ]
+}
+`
+
+	// Paths with an unknown mode are ignored.
+
+	compareTidied(
+		t,
+		NewParams{
+			ArrayKeyOrder: map[string]string{
+				"$.first":   "first",
+				"$.union":   "union",
+				"$.unknown": "last",
+			},
+		},
+		orig,
+		expect,
+	)
+}
+
+func TestDescendingArraySorting(t *testing.T) {
+	orig := `{
+    "reversed": [ "b", "A", "c", "a", "B" ],
+    "stable": [ "b", "A", "c", "a", "B" ],
+    "versions": [ "1.2.0", "1.10.0", "1.2.0+build", "1.9.0" ]
+}`
+
+	expect := `{
+    "reversed": [
+        "c",
+        "B",
+        "b",
+        "a",
+        "A"
+    ],
+    "stable": [
+        "c",
+        "b",
+        "B",
+        "A",
+        "a"
+    ],
+    "versions": [
+        "1.10.0",
+        "1.9.0",
+        "1.2.0",
+        "1.2.0+build"
+    ]
+}
+`
+
+	compareTidied(
+		t,
+		NewParams{ArraySortRules: []ArraySortRule{
+			{Path: "$.reversed", Order: "desc"},
+			{Path: "$.stable", Order: "desc", Stable: "original"},
+			{Path: "$.versions", Comparator: "semver", Order: "desc", Stable: "original"},
+		}},
+		orig,
+		expect,
+	)
+}
+
+func TestNumericArraySorting(t *testing.T) {
+	orig := `{"n": [ 10, 9, -1.5, 1e1, 100 ]}`
+
+	expect := `{
+    "n": [
+        -1.5,
+        9,
+        10,
+        1e1,
+        100
+    ]
+}
+`
+
+	compareTidied(t, NewParams{ArraySort: []string{"$.n"}}, orig, expect)
+}
+
+func TestSortKeys(t *testing.T) {
+	orig := `{"item10": 1, "B": {"z": 1, "a": [{"y": 1, "x": 2}]}, "item2": 2, "a": 3, "ordered": {"z": 1, "a": 2}}`
+
+	expect := `{
+    "a": 3,
+    "B": {
+        "a": [
+            {
+                "x": 2,
+                "y": 1
+            }
+        ],
+        "z": 1
+    },
+    "item10": 1,
+    "item2": 2,
+    "ordered": {
+        "z": 1,
+        "a": 2
+    }
+}
+`
+
+	keyOrder := map[string][]string{"$.ordered": {"z"}}
+	compareTidied(t, NewParams{KeyOrder: keyOrder, SortKeys: "alphabetical"}, orig, expect)
+
+	expect = `{
+    "a": 3,
+    "B": {
+        "a": [
+            {
+                "x": 2,
+                "y": 1
+            }
+        ],
+        "z": 1
+    },
+    "item2": 2,
+    "item10": 1,
+    "ordered": {
+        "z": 1,
+        "a": 2
+    }
+}
+`
+
+	compareTidied(t, NewParams{KeyOrder: keyOrder, SortKeys: "natural"}, orig, expect)
+}
+
+func TestLineEndings(t *testing.T) {
+	orig := "{\r\n\"foo\": 42,\r\n\"bar\": \"hello\"\r\n}\r\n"
+
+	expect := "{\r\n    \"foo\": 42,\r\n    \"bar\": \"hello\"\r\n}\r\n"
+
+	compareTidied(t, NewParams{}, orig, expect)
+	compareTidied(t, NewParams{LineEnding: "auto"}, orig, expect)
+
+	expect = "{\n    \"foo\": 42,\n    \"bar\": \"hello\"\n}\n"
+
+	compareTidied(t, NewParams{LineEnding: "lf"}, orig, expect)
+
+	orig = `{"foo": 42}`
+
+	expect = "{\r\n    \"foo\": 42\r\n}"
+
+	compareTidied(t, NewParams{LineEnding: "crlf", FinalNewline: boolRef(false)}, orig, expect)
+}
+
+func TestBOM(t *testing.T) {
+	orig := "\xef\xbb\xbf{\"foo\": 42}"
+
+	expect := "\xef\xbb\xbf{\n    \"foo\": 42\n}\n"
+
+	compareTidied(t, NewParams{}, orig, expect)
+
+	expect = "{\n    \"foo\": 42\n}\n"
+
+	compareTidied(t, NewParams{BOM: "remove"}, orig, expect)
+
+	expect = "\xef\xbb\xbf{\"foo\":42}\n"
+
+	compareTidied(t, NewParams{BOM: "add", Canonical: true, FinalNewline: boolRef(true)}, `{"foo": 42}`, expect)
+}
+
+func TestAutoIndent(t *testing.T) {
+	orig := `{
+  "foo": {
+    "bar": [1]
+  }
+}`
+
+	expect := `{
+  "foo": {
+    "bar": [
+      1
+    ]
+  }
+}
+`
+
+	compareTidied(t, NewParams{Indent: stringRef("auto")}, orig, expect)
+
+	orig = "{\n\t\"foo\": 42\n}"
+
+	expect = "{\n\t\"foo\": 42\n}\n"
+
+	compareTidied(t, NewParams{Indent: stringRef("auto")}, orig, expect)
+
+	orig = `{"foo": 42}`
+
+	expect = `{
+    "foo": 42
+}
+`
+
+	compareTidied(t, NewParams{Indent: stringRef("auto")}, orig, expect)
+	// Comment lines are not used to detect the indentation.
+	orig = `/**
+ * A header
+ */
+{
+  // The foo
+  "foo": {"bar": 1}
+}`
+
+	expect = `/**
+ * A header
+ */
+{
+  // The foo
+  "foo": {
+    "bar": 1
+  }
+}
+`
+
+	compareTidied(t, NewParams{Indent: stringRef("auto"), JSONC: true}, orig, expect)
+}
+
+func TestRootValues(t *testing.T) {
+	orig := `[ { "name": "b", "id": 2 }, { "name": "a", "id": 1 } ]`
+
+	expect := `[
+    {
+        "id": 1,
+        "name": "a"
+    },
+    {
+        "id": 2,
+        "name": "b"
+    }
+]
+`
+
+	compareTidied(
+		t,
+		NewParams{
+			KeyOrder:       map[string][]string{"$[*]": {"id"}},
+			ArraySortRules: []ArraySortRule{{Path: "$", Comparator: "deep"}},
+		},
+		orig,
+		expect,
+	)
+
+	compareTidied(t, NewParams{}, `"hello"`, "\"hello\"\n")
+	compareTidied(t, NewParams{}, ` 42 `, "42\n")
+	compareTidied(t, NewParams{}, `null`, "null\n")
+	compareTidied(t, NewParams{}, `[]`, "[]\n")
+	compareTidied(t, NewParams{Canonical: true}, `[1.0, {"b": 1, "a": 2}]`, `[1,{"a":2,"b":1}]`)
+
+	jt := NewJSONTidier(NewParams{})
+	_, err := jt.TidyString(`[1] [2]`)
+	assert.NotNil(t, err, "error for more than one root value")
+
+	tidied, err := jt.TidyString(`{"foo": 42}`)
+	assert.Nil(t, err, "no error reusing tidier")
+	assert.Equal(t, "{\n    \"foo\": 42\n}\n", tidied, "reused tidier only has the new document")
+}
+
+func TestJSON5(t *testing.T) {
+	orig := `// A JSON5 document
+{
+  unquoted: 'and you can quote me on that',
+  lineBreaks: "Look, Mom! \
+No \\n's!",
+  hexadecimal: 0xdecaf,
+  leadingDecimalPoint: .8675309, andTrailing: 8675309.,
+  positiveSign: +1,
+  "backwardsCompatible": "with JSON",
+  list: [3, 0x1, +2, -0x0,],
+}
+`
+
+	expect := `// A JSON5 document
+{
+    andTrailing: 8675309.,
+    "backwardsCompatible": "with JSON",
+    hexadecimal: 0xdecaf,
+    leadingDecimalPoint: .8675309,
+    lineBreaks: "Look, Mom! \
+No \\n's!",
+    list: [
+        -0x0,
+        0x1,
+        +2,
+        3
+    ],
+    positiveSign: +1,
+    unquoted: 'and you can quote me on that'
+}
+`
+
+	np := NewParams{JSON5: true, SortKeys: "alphabetical", ArraySort: []string{"$.list"}}
+	compareTidied(t, np, orig, expect)
+
+	expect = `{
+    "andTrailing": 8675309,
+    "backwardsCompatible": "with JSON",
+    "hexadecimal": 912559,
+    "leadingDecimalPoint": 0.8675309,
+    "lineBreaks": "Look, Mom! No \\n's!",
+    "list": [
+        0,
+        1,
+        2,
+        3
+    ],
+    "positiveSign": 1,
+    "unquoted": "and you can quote me on that"
+}
+`
+
+	np.StrictJSON = true
+	compareTidied(t, np, orig, expect)
+
+	jt := NewJSONTidier(np)
+	_, err := jt.TidyString("{\n  a: -Infinity\n}")
+	assert.EqualError(t, err, "line 2, column 6: -Infinity cannot be written as strict JSON", "error for Infinity in strict JSON")
+
+	// A line continuation in a CRLF file keeps its CRLF.
+	orig = "{\r\n  a: \"x\\\r\ny\"\r\n}\r\n"
+	expect = "{\r\n    a: \"x\\\r\ny\"\r\n}\r\n"
+	jt = NewJSONTidier(NewParams{JSON5: true})
+	tidied, err := jt.TidyString(orig)
+	assert.Nil(t, err, "no error calling TidyString")
+	assert.Equal(t, expect, tidied, "got expected tidied JSON5 with CRLF line endings")
+	tidied, err = jt.TidyString(tidied)
+	assert.Nil(t, err, "no error tidying the tidied JSON5 again")
+	assert.Equal(t, expect, tidied, "tidying again gives the same output")
+}
+
+func TestAllowTrailingCommas(t *testing.T) {
+	orig := `{
+    "list": [1, 2,],
+    "obj": {"a": 1,
+    },
+}`
+
+	expect := `{
+    "list": [
+        1,
+        2
+    ],
+    "obj": {
+        "a": 1
+    }
+}
+`
+
+	np := NewParams{AllowTrailingCommas: true}
+	jt := NewJSONTidier(np)
+	tidied, err := jt.TidyString(orig)
+	assert.Nil(t, err, "no error calling TidyString")
+	assert.Equal(t, expect, tidied, "got expected tidied JSON")
+	assert.Equal(
+		t,
+		[]Diagnostic{
+			{Line: 2, Column: 18, Path: "$['list']", Message: "trailing comma"},
+			{Line: 3, Column: 19, Path: "$['obj']", Message: "trailing comma"},
+			{Line: 4, Column: 6, Path: "$", Message: "trailing comma"},
+		},
+		jt.Diagnostics(),
+		"got expected diagnostics",
+	)
+
+	_, err = jt.TidyString(`{"a": 1}`)
+	assert.Nil(t, err, "no error calling TidyString")
+	assert.Empty(t, jt.Diagnostics(), "no diagnostics for a valid document")
+
+	_, err = NewJSONTidier(NewParams{}).TidyString(`[1,]`)
+	assert.NotNil(t, err, "trailing commas are not allowed by default")
+	_, err = jt.TidyString(`[1,,]`)
+	assert.NotNil(t, err, "only one trailing comma is allowed")
+}
+
+func TestStream(t *testing.T) {
+	orig := `{"b": 1, "a": 2}{"c": [2, 1]}
+   3 "x"`
+
+	expect := `{
+    "a": 2,
+    "b": 1
+}
+{
+    "c": [
+        1,
+        2
+    ]
+}
+3
+"x"
+`
+
+	np := NewParams{Stream: true, SortKeys: "alphabetical", ArraySort: []string{"$.c"}}
+	compareTidied(t, np, orig, expect)
+
+	orig = "\x1e{\"b\": 1, \"a\": 2}\n\x1e[1]\n"
+
+	expect = "\x1e{\"a\":2,\"b\":1}\n\x1e[1]\n"
+
+	np.Minify = true
+	compareTidied(t, np, orig, expect)
+
+	compareTidied(t, np, "", "")
+
+	_, err := NewJSONTidier(np).TidyString(`{"a": 1} {"b": }`)
+	assert.EqualError(t, err, `line 1, column 16: invalid character '}'`, "error in second document")
+}
+
+func TestDuplicateKeys(t *testing.T) {
+	orig := `{
+    "b": 1,
+    "a": {"x": 1, "x": 2},
+    "b": 3
+}`
+
+	_, err := NewJSONTidier(NewParams{}).TidyString(orig)
+	assert.EqualError(
+		t,
+		err,
+		`line 3, column 19: duplicate key "x" in $['a']`,
+		"duplicate keys are an error by default",
+	)
+
+	expect := map[string]string{
+		"keep-first": `{
+    "b": 1,
+    "a": {
+        "x": 1
+    }
+}
+`,
+		"keep-last": `{
+    "b": 3,
+    "a": {
+        "x": 2
+    }
+}
+`,
+		"keep-all": `{
+    "b": 1,
+    "b": 3,
+    "a": {
+        "x": 1,
+        "x": 2
+    }
+}
+`,
+	}
+	for policy, e := range expect {
+		jt := NewJSONTidier(NewParams{DuplicateKeys: policy})
+		tidied, err := jt.TidyString(orig)
+		assert.Nil(t, err, "no error calling TidyString with %s", policy)
+		assert.Equal(t, e, tidied, "got expected tidied JSON with %s", policy)
+		assert.Equal(
+			t,
+			[]Diagnostic{
+				{Line: 3, Column: 19, Path: "$['a']['x']", Message: `duplicate key "x"`},
+				{Line: 4, Column: 5, Path: "$['b']", Message: `duplicate key "b"`},
+			},
+			jt.Diagnostics(),
+			"got expected diagnostics with %s",
+			policy,
+		)
+	}
+
+	_, err = NewJSONTidier(NewParams{DuplicateKeys: "keep-some"}).TidyString(orig)
+	assert.NotNil(t, err, "an unknown policy is treated as an error")
+
+	_, err = NewJSONTidier(NewParams{DuplicateKeys: "keep-all", Canonical: true}).TidyString(orig)
+	assert.NotNil(t, err, "duplicate keys cannot be written as canonical JSON")
+}
+
+func TestEmbeddedJSON(t *testing.T) {
+	orig := `{
+    "policy": "{\"Version\": \"2012-10-17\", \"Statement\": [{\"Effect\": \"Allow\", \"Action\": [\"s3:Put\", \"s3:Get\"]}]}",
+    "pretty": ["{\"b\": 1, \"a\": 2}"],
+    "other": "{\"b\": 1, \"a\": 2}"
+}`
+
+	expect := `{
+    "policy": "{\"Version\":\"2012-10-17\",\"Statement\":[{\"Action\":[\"s3:Get\",\"s3:Put\"],\"Effect\":\"Allow\"}]}",
+    "pretty": [
+        "{\n  \"a\": 2,\n  \"b\": 1\n}"
+    ],
+    "other": "{\"b\": 1, \"a\": 2}"
+}
+`
+
+	indent := "  "
+	np := NewParams{
+		EmbeddedJSON: map[string]EmbeddedJSONRule{
+			"$.policy": {
+				Params: NewParams{
+					KeyOrder:  map[string][]string{"$": {"Version", "Statement"}},
+					ArraySort: []string{"$..Action"},
+					SortKeys:  "alphabetical",
+				},
+			},
+			"$.pretty[*]": {
+				Output: "pretty",
+				Params: NewParams{Indent: &indent, SortKeys: "alphabetical"},
+			},
+		},
+	}
+	compareTidied(t, np, orig, expect)
+
+	_, err := NewJSONTidier(np).TidyString(`{"policy": "not JSON"}`)
+	assert.NotNil(t, err, "error for a string which is not valid JSON")
+}
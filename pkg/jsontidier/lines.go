@@ -0,0 +1,105 @@
+package jsontidier
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// TidyLines tidies JSON Lines (also known as NDJSON) read from r and writes
+// the result to w. Each line is parsed as its own document, so "$" matches
+// the root of each line, and each document is written back on a single line
+// with no whitespace. Blank lines are kept as they are. The input is read
+// one line at a time, so it is never held in memory all at once. Errors
+// include the line number of the document that could not be parsed, and
+// Diagnostics returns the problems fixed in every line. The last line ends
+// with a line ending unless FinalNewline is false, even with Canonical.
+func (jt *JSONTidier) TidyLines(r io.Reader, w io.Writer) error {
+	br := bufio.NewReader(r)
+	bw := bufio.NewWriter(w)
+
+	var diagnostics []Diagnostic
+	defer func() {
+		jt.diagnostics = diagnostics
+	}()
+
+	// The line ending for each line is only written once we know whether
+	// there is another line after it. A last line without a line ending gets
+	// a CRLF if any of the lines before it had one.
+	var eol []byte
+	crlf := jt.lineEnding == "crlf"
+
+	for n := 1; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(line) == 0 {
+			break
+		}
+
+		if _, err := bw.Write(eol); err != nil {
+			return err
+		}
+		eol = []byte{}
+		if bytes.HasSuffix(line, []byte("\n")) {
+			eol = []byte("\n")
+			if jt.lineEnding != "lf" && bytes.HasSuffix(line, []byte("\r\n")) {
+				crlf = true
+			}
+			if jt.lineEnding == "crlf" || (jt.lineEnding != "lf" && bytes.HasSuffix(line, []byte("\r\n"))) {
+				eol = []byte("\r\n")
+			}
+		}
+		if n == 1 && bytes.HasPrefix(line, utf8BOM) {
+			line = line[len(utf8BOM):]
+			if jt.bom != "remove" {
+				bw.Write(utf8BOM)
+			}
+		} else if n == 1 && jt.bom == "add" {
+			bw.Write(utf8BOM)
+		}
+
+		if len(bytes.TrimSpace(line)) > 0 {
+			tidied, err := jt.tidyLine(line, n)
+			diagnostics = append(diagnostics, jt.diagnostics...)
+			if err != nil {
+				return err
+			}
+			if _, err := bw.Write(tidied); err != nil {
+				return err
+			}
+			if len(eol) == 0 {
+				eol = []byte("\n")
+				if crlf {
+					eol = []byte("\r\n")
+				}
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	if jt.finalNewline == nil || *jt.finalNewline {
+		if _, err := bw.Write(eol); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func (jt *JSONTidier) tidyLine(doc []byte, n int) ([]byte, error) {
+	err := jt.unmarshal(doc, n)
+	if err != nil {
+		return nil, err
+	}
+
+	if jt.canonical {
+		return appendCanonical(nil, jt.root())
+	}
+	p := jt.newPrinter()
+	p.compact()
+	return p.print(jt.root(), nil)
+}
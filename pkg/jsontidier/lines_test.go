@@ -0,0 +1,68 @@
+package jsontidier
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTidyLines(t *testing.T) {
+	orig := `{"user": "b", "id": 2, "tags": ["y", "x"]}
+  {  "id" : 1 }
+
+[{"user": "a", "id": 3}]
+"scalar"`
+
+	expect := `{"id":2,"tags":["x","y"],"user":"b"}
+{"id":1}
+
+[{"id":3,"user":"a"}]
+"scalar"
+`
+
+	jt := NewJSONTidier(NewParams{
+		KeyOrder:  map[string][]string{"$": {"id"}, "$[*]": {"id"}},
+		ArraySort: []string{"$.tags"},
+	})
+	var out bytes.Buffer
+	err := jt.TidyLines(strings.NewReader(orig), &out)
+	assert.Nil(t, err, "no error calling TidyLines")
+	assert.Equal(t, expect, out.String(), "got expected tidied JSON Lines")
+
+	out.Reset()
+	err = jt.TidyLines(strings.NewReader("{\"a\": 1}\r\n{\"b\": 2}\r\n"), &out)
+	assert.Nil(t, err, "no error calling TidyLines")
+	assert.Equal(t, "{\"a\":1}\r\n{\"b\":2}\r\n", out.String(), "line endings are preserved")
+
+	err = jt.TidyLines(strings.NewReader("{\"a\": 1}\n\n{\"b\": x}\n"), &out)
+	assert.EqualError(t, err, "line 3, column 7: invalid character 'x'", "error includes the line number")
+}
+
+func TestTidyLinesFinalNewline(t *testing.T) {
+	finalNewline := false
+	jt := NewJSONTidier(NewParams{FinalNewline: &finalNewline})
+	var out bytes.Buffer
+	err := jt.TidyLines(strings.NewReader("{\"a\": 1}\n{\"b\": 2}\n"), &out)
+	assert.Nil(t, err, "no error calling TidyLines")
+	assert.Equal(t, "{\"a\":1}\n{\"b\":2}", out.String(), "no line ending after the last line")
+
+	jt = NewJSONTidier(NewParams{Canonical: true})
+	out.Reset()
+	err = jt.TidyLines(strings.NewReader("{\"a\": 1}\n{\"b\": 2}"), &out)
+	assert.Nil(t, err, "no error calling TidyLines")
+	assert.Equal(t, "{\"a\":1}\n{\"b\":2}\n", out.String(), "the last line ends with a line ending by default")
+
+	jt = NewJSONTidier(NewParams{})
+	out.Reset()
+	err = jt.TidyLines(strings.NewReader("{\"b\":1}\r\n{\"x\":1}"), &out)
+	assert.Nil(t, err, "no error calling TidyLines")
+	assert.Equal(t, "{\"b\":1}\r\n{\"x\":1}\r\n", out.String(), "the last line ending matches the other lines")
+
+	jt = NewJSONTidier(NewParams{LineEnding: "crlf"})
+	out.Reset()
+	err = jt.TidyLines(strings.NewReader("{\"x\":1}"), &out)
+	assert.Nil(t, err, "no error calling TidyLines")
+	assert.Equal(t, "{\"x\":1}\r\n", out.String(), "the last line ending follows LineEnding")
+}
@@ -0,0 +1,170 @@
+package jsontidier
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// MarkdownError is returned by TidyMarkdown when some of the JSON code blocks
+// in a Markdown document could not be tidied. The document returned along
+// with it has every other block tidied.
+type MarkdownError struct {
+	Blocks []error
+}
+
+func (e *MarkdownError) Error() string {
+	msgs := make([]string, len(e.Blocks))
+	for i, err := range e.Blocks {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// TidyMarkdown tidies every fenced code block in a Markdown document whose
+// language is "json" or "jsonc". Comments are allowed in "jsonc" blocks.
+// Everything outside of these blocks is left exactly as it was. Blocks which
+// are indented, such as those in list items, keep their indentation, and line
+// numbers in errors and Diagnostics are line numbers in the whole document.
+// If any blocks cannot be parsed they are left as they were, and a
+// *MarkdownError listing them is returned along with the tidied document.
+// The BOM setting applies to the whole document. The LineEnding setting only
+// applies to the lines inside tidied blocks, and with "auto" each block keeps
+// the line ending of its first line.
+func (jt *JSONTidier) TidyMarkdown(doc []byte) ([]byte, error) {
+	hasBOM := bytes.HasPrefix(doc, utf8BOM)
+	lines := bytes.SplitAfter(bytes.TrimPrefix(doc, utf8BOM), []byte("\n"))
+
+	var out []byte
+	var diagnostics []Diagnostic
+	var invalid []error
+	for i := 0; i < len(lines); i++ {
+		out = append(out, lines[i]...)
+		indent, fence, lang, ok := openingFence(lines[i])
+		if !ok {
+			continue
+		}
+
+		end := i + 1
+		for end < len(lines) && !closingFence(lines[end], fence) {
+			end++
+		}
+		if end == len(lines) {
+			// A block which is never closed runs to the end of the document,
+			// so we leave it alone.
+			out = append(out, bytes.Join(lines[i+1:], nil)...)
+			break
+		}
+
+		block := bytes.Join(lines[i+1:end], nil)
+		if lang == "json" || lang == "jsonc" {
+			tidied, err := jt.tidyBlock(block, indent, lang == "jsonc", i+2)
+			diagnostics = append(diagnostics, jt.diagnostics...)
+			if err != nil {
+				invalid = append(invalid, fmt.Errorf("the %s block starting on line %d is not valid: %s", lang, i+1, err))
+			} else {
+				block = tidied
+			}
+		}
+		out = append(out, block...)
+		out = append(out, lines[end]...)
+		i = end
+	}
+
+	if jt.bom == "add" || (jt.bom != "remove" && hasBOM) {
+		out = append(append([]byte{}, utf8BOM...), out...)
+	}
+
+	jt.diagnostics = diagnostics
+	if len(invalid) > 0 {
+		return out, &MarkdownError{invalid}
+	}
+	return out, nil
+}
+
+// tidyBlock tidies the contents of a code block which starts on the given
+// line of a Markdown document. The indent is the number of spaces before
+// the block's opening fence, which are removed from each line of the block
+// before tidying it and added back afterwards.
+func (jt *JSONTidier) tidyBlock(block []byte, indent int, jsonc bool, line int) ([]byte, error) {
+	jt.diagnostics = nil
+	if len(bytes.TrimSpace(block)) == 0 {
+		return block, nil
+	}
+
+	prefix := strings.Repeat(" ", indent)
+	var data []byte
+	for _, l := range bytes.SplitAfter(block, []byte("\n")) {
+		for i := 0; i < indent && len(l) > 0 && l[0] == ' '; i++ {
+			l = l[1:]
+		}
+		data = append(data, l...)
+	}
+
+	defer func(jsonc bool) {
+		jt.jsonc = jsonc
+	}(jt.jsonc)
+	jt.jsonc = jt.jsonc || jsonc
+
+	err := jt.unmarshal(data, line)
+	if err != nil {
+		return nil, err
+	}
+	tidied, err := jt.render(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []byte
+	eol := "\n"
+	if jt.lineEnding == "crlf" || (jt.lineEnding != "lf" && usesCRLF(block)) {
+		eol = "\r\n"
+	}
+	for _, l := range strings.Split(string(tidied), "\n") {
+		if l != "" {
+			out = append(out, prefix...)
+		}
+		out = append(out, l...)
+		out = append(out, eol...)
+	}
+	return out, nil
+}
+
+// openingFence returns the indentation, fence and language of a line which
+// opens a fenced code block, as described by CommonMark. The language is the
+// first word of the info string after the fence, in lower case.
+func openingFence(line []byte) (indent int, fence string, lang string, ok bool) {
+	s := strings.TrimRight(string(line), "\r\n")
+	for indent < 3 && strings.HasPrefix(s[indent:], " ") {
+		indent++
+	}
+	s = s[indent:]
+	if !strings.HasPrefix(s, "```") && !strings.HasPrefix(s, "~~~") {
+		return 0, "", "", false
+	}
+
+	n := 0
+	for n < len(s) && s[n] == s[0] {
+		n++
+	}
+	info := strings.TrimSpace(s[n:])
+	if s[0] == '`' && strings.Contains(info, "`") {
+		return 0, "", "", false
+	}
+	if fields := strings.Fields(info); len(fields) > 0 {
+		lang = strings.ToLower(fields[0])
+	}
+	return indent, s[:n], lang, true
+}
+
+// closingFence returns true if line closes a code block opened with fence.
+func closingFence(line []byte, fence string) bool {
+	s := strings.TrimRight(string(line), "\r\n")
+	for i := 0; i < 3 && strings.HasPrefix(s, " "); i++ {
+		s = s[1:]
+	}
+	if !strings.HasPrefix(s, fence) {
+		return false
+	}
+	return strings.TrimSpace(strings.TrimLeft(s, fence[:1])) == ""
+}
@@ -0,0 +1,151 @@
+package jsontidier
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTidyMarkdown(t *testing.T) {
+	orig := "# Example\n" +
+		"\n" +
+		"```json\n" +
+		`{"name": "x", "id": 1}` + "\n" +
+		"```\n" +
+		"\n" +
+		"1. A list item:\n" +
+		"\n" +
+		"   ~~~ JSONC title=\"config\"\n" +
+		"   {\n" +
+		"     // The id\n" +
+		"     \"name\": \"y\", \"id\": 2}\n" +
+		"   ~~~\n" +
+		"\n" +
+		"```js\n" +
+		`{"name": "z", "id": 3}` + "\n" +
+		"```\n" +
+		"\n" +
+		"````json\n" +
+		"{\"a\": 1,\n" +
+		"```\n" +
+		"````\n"
+
+	expect := "# Example\n" +
+		"\n" +
+		"```json\n" +
+		"{\n" +
+		"    \"id\": 1,\n" +
+		"    \"name\": \"x\"\n" +
+		"}\n" +
+		"```\n" +
+		"\n" +
+		"1. A list item:\n" +
+		"\n" +
+		"   ~~~ JSONC title=\"config\"\n" +
+		"   {\n" +
+		"       \"id\": 2,\n" +
+		"       // The id\n" +
+		"       \"name\": \"y\"\n" +
+		"   }\n" +
+		"   ~~~\n" +
+		"\n" +
+		"```js\n" +
+		`{"name": "z", "id": 3}` + "\n" +
+		"```\n" +
+		"\n" +
+		"````json\n" +
+		"{\"a\": 1,\n" +
+		"```\n" +
+		"````\n"
+
+	jt := NewJSONTidier(NewParams{KeyOrder: map[string][]string{"$": {"id"}}})
+	tidied, err := jt.TidyMarkdown([]byte(orig))
+	assert.Equal(t, expect, string(tidied), "got expected tidied Markdown")
+	if assert.IsType(t, &MarkdownError{}, err, "got a MarkdownError for the invalid block") {
+		blocks := err.(*MarkdownError).Blocks
+		if assert.Len(t, blocks, 1, "one invalid block") {
+			assert.Contains(t, blocks[0].Error(), "the json block starting on line 19", "error includes the line of the block")
+		}
+	}
+
+	valid := strings.TrimSuffix(expect, "\n````json\n{\"a\": 1,\n```\n````\n")
+	tidied, err = jt.TidyMarkdown([]byte(valid))
+	assert.Nil(t, err, "no error calling TidyMarkdown on a document without invalid blocks")
+	assert.Equal(t, valid, string(tidied), "tidied Markdown is unchanged")
+}
+
+func TestTidyMarkdownAfterInvalidBlock(t *testing.T) {
+	orig := "```json\n" +
+		`{"x": [1, }` + "\n" +
+		"```\n" +
+		"```json\n" +
+		`{"b": 1, "a": 2}` + "\n" +
+		"```\n"
+
+	expect := "```json\n" +
+		`{"x": [1, }` + "\n" +
+		"```\n" +
+		"```json\n" +
+		"{\n" +
+		"    \"a\": 2,\n" +
+		"    \"b\": 1\n" +
+		"}\n" +
+		"```\n"
+
+	jt := NewJSONTidier(NewParams{KeyOrder: map[string][]string{"$": {"a", "b"}}})
+	tidied, err := jt.TidyMarkdown([]byte(orig))
+	assert.IsType(t, &MarkdownError{}, err, "got a MarkdownError for the invalid block")
+	assert.Equal(t, expect, string(tidied), "rules still apply to the block after an invalid one")
+
+	_, err = jt.TidyString(`{"x": [1, }`)
+	assert.NotNil(t, err, "error for invalid JSON")
+	tidied2, err := jt.TidyString(`{"b": 1, "a": 2}`)
+	assert.Nil(t, err, "no error calling TidyString")
+	assert.Equal(t, "{\n    \"a\": 2,\n    \"b\": 1\n}\n", tidied2, "rules still apply after an error")
+}
+
+func TestTidyMarkdownLineEndingAndBOM(t *testing.T) {
+	orig := "\xef\xbb\xbf# Example\r\n" +
+		"```json\r\n" +
+		"{\"a\": 1}\r\n" +
+		"```\r\n"
+
+	// Only the lines in the block get the line ending from the settings.
+	expect := "# Example\r\n" +
+		"```json\r\n" +
+		"{\n" +
+		"    \"a\": 1\n" +
+		"}\n" +
+		"```\r\n"
+
+	jt := NewJSONTidier(NewParams{LineEnding: "lf", BOM: "remove"})
+	tidied, err := jt.TidyMarkdown([]byte(orig))
+	assert.Nil(t, err, "no error calling TidyMarkdown")
+	assert.Equal(t, expect, string(tidied), "line endings and BOM settings are applied")
+
+	expect = "\xef\xbb\xbf# Example\r\n" +
+		"```json\r\n" +
+		"{\r\n" +
+		"    \"a\": 1\r\n" +
+		"}\r\n" +
+		"```\r\n"
+
+	jt = NewJSONTidier(NewParams{})
+	tidied, err = jt.TidyMarkdown([]byte(orig))
+	assert.Nil(t, err, "no error calling TidyMarkdown")
+	assert.Equal(t, expect, string(tidied), "line endings and BOM are kept by default")
+
+	// Prose keeps its line endings whatever the setting is.
+	orig = "# Example\r\n" +
+		"```json\n" +
+		"{\n" +
+		"    \"a\": 1\n" +
+		"}\n" +
+		"```\r\n"
+
+	jt = NewJSONTidier(NewParams{LineEnding: "lf"})
+	tidied, err = jt.TidyMarkdown([]byte(orig))
+	assert.Nil(t, err, "no error calling TidyMarkdown")
+	assert.Equal(t, orig, string(tidied), "a tidy document is unchanged")
+}
@@ -0,0 +1,74 @@
+package jsontidier
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// normalizeNumber rewrites n in its shortest form without changing its value.
+// Leading and trailing zeros and redundant exponents are removed, negative
+// zero becomes "0", and any number which is an integer is written as a plain
+// integer. An exponent is only used if the original number had one, and then,
+// like ECMAScript, only for numbers of 1e21 or more, or less than 1e-6.
+// Unlike canonical output, every digit of the original is kept, so no
+// precision is lost. Numbers with exponents too large to handle are returned
+// unchanged.
+func normalizeNumber(n json.Number) json.Number {
+	s := string(n)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign = "-"
+		s = s[1:]
+	}
+
+	exp := 0
+	hasExp := false
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		hasExp = true
+		e, err := strconv.ParseInt(s[i+1:], 10, 32)
+		if err != nil {
+			return n
+		}
+		exp = int(e)
+		s = s[:i]
+	}
+
+	digits := s
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		digits = s[:i] + s[i+1:]
+		exp -= len(s) - i - 1
+	}
+
+	digits = strings.TrimLeft(digits, "0")
+	if digits == "" {
+		return "0"
+	}
+	trimmed := strings.TrimRight(digits, "0")
+	exp += len(digits) - len(trimmed)
+	digits = trimmed
+
+	// The value is now digits * 10^exp, and point is where the decimal point
+	// goes relative to the start of digits.
+	point := len(digits) + exp
+	switch {
+	case exp >= 0 && (point <= 21 || !hasExp):
+		s = digits + strings.Repeat("0", exp)
+	case point > 0 && (point <= 21 || !hasExp):
+		s = digits[:point] + "." + digits[point:]
+	case point <= 0 && (point > -6 || !hasExp):
+		s = "0." + strings.Repeat("0", -point) + digits
+	default:
+		s = digits[:1]
+		if len(digits) > 1 {
+			s += "." + digits[1:]
+		}
+		s += "e"
+		if point > 0 {
+			s += "+"
+		}
+		s += strconv.Itoa(point - 1)
+	}
+
+	return json.Number(sign + s)
+}
@@ -0,0 +1,64 @@
+package jsontidier
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeNumber(t *testing.T) {
+	tests := []struct {
+		orig, expect string
+	}{
+		{"150", "150"},
+		{"1.50E+2", "150"},
+		{"-0", "0"},
+		{"-0.0e10", "0"},
+		{"1.0", "1"},
+		{"-12.3400", "-12.34"},
+		{"0.5", "0.5"},
+		{"1e2", "100"},
+		{"12345e-2", "123.45"},
+		{"0.000001", "0.000001"},
+		{"1e-7", "1e-7"},
+		{"1.25e-7", "1.25e-7"},
+		{"1e21", "1e+21"},
+		{"123456789012345678901", "123456789012345678901"},
+		{"1234567890123456789012", "1234567890123456789012"},
+		{"1234567890123456789012.5", "1234567890123456789012.5"},
+		{"0.00000012500", "0.000000125"},
+		{"12345678901234567890120e-1", "1.234567890123456789012e+21"},
+		{"0.10000000000000000000000001", "0.10000000000000000000000001"},
+		{"1e99999999999", "1e99999999999"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(
+			t,
+			json.Number(test.expect),
+			normalizeNumber(json.Number(test.orig)),
+			"normalized %s", test.orig,
+		)
+	}
+}
+
+func TestNormalizeNumbers(t *testing.T) {
+	orig := `{
+    "data": {"price": 1.50E+2, "offset": -0, "ids": [1.0, 2e0]},
+    "raw": [1.50E+2, -0]
+}`
+
+	expect := `{
+    "data": {"price": 150, "offset": 0, "ids": [1, 2]},
+    "raw": [1.50E+2, -0]
+}
+`
+
+	compareTidied(
+		t,
+		NewParams{NormalizeNumbers: []string{"$.data"}, MaxLineWidth: 60},
+		orig,
+		expect,
+	)
+}
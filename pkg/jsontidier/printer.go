@@ -0,0 +1,526 @@
+package jsontidier
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// printer turns a parsed document back into text. Arrays and objects are
+// written with one element per line, except that when maxLineWidth is
+// greater than zero, any array or object which fits on its line without
+// exceeding that width is written on a single line. The formats can override
+// this for the arrays and objects at particular paths, along with everything
+// inside them, and the root format applies to the whole document. Arrays and
+// objects containing comments are always written with one element per line,
+// unless comments is false, in which case the comments are dropped.
+type printer struct {
+	indent          string
+	maxLineWidth    int
+	formats         map[*regexp.Regexp]string
+	escapeHTML      bool
+	escapes         string
+	alignValues     bool
+	alignMaxPadding int
+	comments        bool
+	root            string
+	path            []string
+	out             []byte
+}
+
+func (jt *JSONTidier) newPrinter() *printer {
+	p := &printer{
+		indent:          jt.indent,
+		maxLineWidth:    jt.maxLineWidth,
+		formats:         jt.formats,
+		escapeHTML:      jt.escapeHTML,
+		escapes:         jt.stringEscapes,
+		alignValues:     jt.alignValues,
+		alignMaxPadding: jt.alignMaxPadding,
+		comments:        !jt.strictJSON,
+		path:            []string{"$"},
+	}
+	if jt.minify {
+		p.compact()
+	}
+
+	return p
+}
+
+// compact makes the printer write everything on a single line with no
+// whitespace and no comments.
+func (p *printer) compact() {
+	p.formats = nil
+	p.root = "compact"
+	p.comments = false
+}
+
+// print writes v, along with the comments before and after the document and
+// before the closing bracket of v.
+func (p *printer) print(v interface{}, c *comments) ([]byte, error) {
+	if !p.comments {
+		c = nil
+	}
+
+	if c != nil {
+		for _, text := range c.before {
+			p.out = append(p.out, text...)
+			p.out = append(p.out, '\n')
+		}
+	}
+	err := p.writeValue(v, 0, 0, 0, p.root, c.endComments())
+	if err != nil {
+		return nil, err
+	}
+	if c != nil {
+		for _, text := range c.after {
+			p.out = append(p.out, '\n')
+			p.out = append(p.out, text...)
+		}
+	}
+
+	return p.out, nil
+}
+
+// format returns the format for the value at the current path, or the
+// inherited format if no format path matches.
+func (p *printer) format(inherited string) string {
+	if len(p.formats) == 0 {
+		return inherited
+	}
+
+	cur := strings.Join(p.path, "")
+	for re, f := range p.formats {
+		if re.MatchString(cur) {
+			return f
+		}
+	}
+
+	return inherited
+}
+
+func (p *printer) pushKey(k string) {
+	p.path = append(p.path, fmt.Sprintf(`['%s']`, k))
+}
+
+func (p *printer) pushIndex(i int) {
+	p.path = append(p.path, fmt.Sprintf("[%d]", i))
+}
+
+func (p *printer) popPath() {
+	p.path = p.path[:len(p.path)-1]
+}
+
+// writeValue writes v at the given depth. The column is the width of
+// everything already written on the current line, and suffix is the width of
+// anything which will be written after the value on the same line, like a
+// trailing comma. The format is the one inherited from the enclosing array or
+// object. If v is an array or object, the end comments are written before its
+// closing bracket.
+func (p *printer) writeValue(v interface{}, depth, column, suffix int, format string, end []string) error {
+	switch v := v.(type) {
+	case *JSONTidier:
+		if len(v.keyOrder) == 0 && len(end) == 0 {
+			p.out = append(p.out, "{}"...)
+			return nil
+		}
+		format = p.format(format)
+		if len(end) == 0 && p.writeInline(v, column+suffix, format) {
+			return nil
+		}
+		return p.writeObject(v, depth, format, end)
+	case []interface{}:
+		if len(v) == 0 && len(end) == 0 {
+			p.out = append(p.out, "[]"...)
+			return nil
+		}
+		format = p.format(format)
+		if len(end) == 0 && p.writeInline(v, column+suffix, format) {
+			return nil
+		}
+		return p.writeArray(v, depth, format, end)
+	}
+
+	var err error
+	p.out, err = p.appendScalar(p.out, v)
+	return err
+}
+
+func (p *printer) writeObject(o *JSONTidier, depth int, format string, end []string) error {
+	align := p.alignment(o)
+
+	p.out = append(p.out, '{')
+	members := o.members()
+	for i, m := range members {
+		k := m.key
+		if i > 0 && m.first && o.blankBefore[k] {
+			p.out = append(p.out, '\n')
+		}
+		c := p.memberComments(o, k)
+		if m.first {
+			p.writeComments(c, depth+1)
+		}
+		p.newline(depth + 1)
+		start := len(p.out)
+		p.out = p.appendKey(p.out, o, k)
+		p.out = append(p.out, ':')
+		if align > 0 {
+			pad := align - utf8.RuneCount(p.out[start:])
+			p.out = append(p.out, strings.Repeat(" ", pad)...)
+		}
+		p.out = append(p.out, ' ')
+
+		column := p.width(depth+1) + utf8.RuneCount(p.out[start:])
+		var end []string
+		if m.last {
+			end = c.endComments()
+		}
+		p.pushKey(k)
+		err := p.writeValue(m.value, depth+1, column, commaWidth(i, len(members)), format, end)
+		p.popPath()
+		if err != nil {
+			return err
+		}
+		if i != len(members)-1 {
+			p.out = append(p.out, ',')
+		}
+		if m.last {
+			p.writeAfterComments(c)
+		}
+	}
+	p.writeEndComments(end, depth+1)
+	p.newline(depth)
+	p.out = append(p.out, '}')
+
+	return nil
+}
+
+// memberComments returns the comments for an object member, or nil if it has
+// none or comments are not being written.
+func (p *printer) memberComments(o *JSONTidier, k string) *comments {
+	if !p.comments {
+		return nil
+	}
+	return o.comments[k]
+}
+
+// element returns an array element along with its comments, or nil comments
+// if it has none or comments are not being written.
+func (p *printer) element(v interface{}) (interface{}, *comments) {
+	c, ok := v.(commented)
+	if !ok {
+		return v, nil
+	}
+	if !p.comments {
+		return c.value, nil
+	}
+	return c.value, c.comments
+}
+
+func (p *printer) writeComments(c *comments, depth int) {
+	if c == nil {
+		return
+	}
+	for _, text := range c.before {
+		p.newline(depth)
+		p.out = append(p.out, text...)
+	}
+}
+
+func (p *printer) writeAfterComments(c *comments) {
+	if c == nil {
+		return
+	}
+	for _, text := range c.after {
+		p.out = append(p.out, ' ')
+		p.out = append(p.out, text...)
+	}
+}
+
+func (p *printer) writeEndComments(end []string, depth int) {
+	for _, text := range end {
+		p.newline(depth)
+		p.out = append(p.out, text...)
+	}
+}
+
+// alignment returns the width that each key and its colon should be padded
+// to so that the object's values line up, or zero if they should not be
+// aligned. Values are only aligned when alignValues is set, every value in
+// the object is a scalar, and no key needs more than alignMaxPadding spaces
+// of padding.
+func (p *printer) alignment(o *JSONTidier) int {
+	if !p.alignValues {
+		return 0
+	}
+
+	min, max := -1, 0
+	for _, m := range o.members() {
+		switch m.value.(type) {
+		case *JSONTidier, []interface{}:
+			return 0
+		}
+
+		w := utf8.RuneCount(p.appendKey(nil, o, m.key)) + 1
+		if min < 0 || w < min {
+			min = w
+		}
+		if w > max {
+			max = w
+		}
+	}
+
+	if p.alignMaxPadding > 0 && max-min > p.alignMaxPadding {
+		return 0
+	}
+	return max
+}
+
+func (p *printer) writeArray(arr []interface{}, depth int, format string, end []string) error {
+	p.out = append(p.out, '[')
+	for i, v := range arr {
+		v, c := p.element(v)
+		p.writeComments(c, depth+1)
+		p.newline(depth + 1)
+		p.pushIndex(i)
+		err := p.writeValue(v, depth+1, p.width(depth+1), commaWidth(i, len(arr)), format, c.endComments())
+		p.popPath()
+		if err != nil {
+			return err
+		}
+		if i != len(arr)-1 {
+			p.out = append(p.out, ',')
+		}
+		p.writeAfterComments(c)
+	}
+	p.writeEndComments(end, depth+1)
+	p.newline(depth)
+	p.out = append(p.out, ']')
+
+	return nil
+}
+
+// writeInline writes v on a single line and returns true if the format is
+// "inline" or "compact", or if there is no format and v fits in the space
+// left on the current line. Otherwise it writes nothing and returns false.
+func (p *printer) writeInline(v interface{}, used int, format string) bool {
+	limit := -1
+	switch format {
+	case "inline", "compact":
+	case "expanded":
+		return false
+	default:
+		if p.maxLineWidth <= 0 || hasBlankLines(v) {
+			return false
+		}
+		limit = p.maxLineWidth - used
+	}
+
+	b, ok := p.appendInline(nil, v, format == "compact", limit)
+	if !ok || (limit >= 0 && utf8.RuneCount(b) > limit) {
+		return false
+	}
+	p.out = append(p.out, b...)
+
+	return true
+}
+
+// appendInline appends v to buf as a single line. Unless compact is true,
+// there is a space after each comma and colon. It gives up and returns false
+// if it finds any comments. If limit is not negative it also gives up as soon
+// as the appended text is more than limit characters long, or if it finds
+// anything with the "expanded" format.
+func (p *printer) appendInline(buf []byte, v interface{}, compact bool, limit int) ([]byte, bool) {
+	comma, colon := ", ", ": "
+	if compact {
+		comma, colon = ",", ":"
+	}
+
+	start := len(buf)
+	remaining := func() int {
+		if limit < 0 {
+			return limit
+		}
+		return limit - utf8.RuneCount(buf[start:])
+	}
+
+	var ok bool
+	switch v := v.(type) {
+	case *JSONTidier:
+		if p.comments && len(v.comments) > 0 {
+			return buf, false
+		}
+		buf = append(buf, '{')
+		for i, m := range v.members() {
+			if i > 0 {
+				buf = append(buf, comma...)
+			}
+			buf = p.appendKey(buf, v, m.key)
+			buf = append(buf, colon...)
+			p.pushKey(m.key)
+			buf, ok = p.appendInlineChild(buf, m.value, compact, remaining())
+			p.popPath()
+			if !ok {
+				return buf, false
+			}
+		}
+		buf = append(buf, '}')
+	case []interface{}:
+		buf = append(buf, '[')
+		for i, e := range v {
+			if i > 0 {
+				buf = append(buf, comma...)
+			}
+			e, c := p.element(e)
+			if c != nil {
+				return buf, false
+			}
+			p.pushIndex(i)
+			buf, ok = p.appendInlineChild(buf, e, compact, remaining())
+			p.popPath()
+			if !ok {
+				return buf, false
+			}
+		}
+		buf = append(buf, ']')
+	default:
+		var err error
+		buf, err = p.appendScalar(buf, v)
+		if err != nil {
+			return buf, false
+		}
+	}
+
+	return buf, limit < 0 || utf8.RuneCount(buf[start:]) <= limit
+}
+
+// appendInlineChild appends an element of an array or object which is being
+// written on a single line, checking whether the element has its own format.
+func (p *printer) appendInlineChild(buf []byte, v interface{}, compact bool, limit int) ([]byte, bool) {
+	switch v.(type) {
+	case *JSONTidier, []interface{}:
+		switch p.format("") {
+		case "inline":
+			compact = false
+		case "compact":
+			compact = true
+		case "expanded":
+			if limit >= 0 {
+				return buf, false
+			}
+		case "":
+			if limit >= 0 && hasBlankLines(v) {
+				return buf, false
+			}
+		}
+	}
+
+	return p.appendInline(buf, v, compact, limit)
+}
+
+func hasBlankLines(v interface{}) bool {
+	o, ok := v.(*JSONTidier)
+	return ok && len(o.blankBefore) > 0
+}
+
+func (p *printer) newline(depth int) {
+	p.out = append(p.out, '\n')
+	p.out = append(p.out, strings.Repeat(p.indent, depth)...)
+}
+
+func (p *printer) width(depth int) int {
+	return utf8.RuneCountInString(p.indent) * depth
+}
+
+func commaWidth(i, l int) int {
+	if i == l-1 {
+		return 0
+	}
+	return 1
+}
+
+func (p *printer) appendKey(buf []byte, o *JSONTidier, k string) []byte {
+	if raw, ok := o.rawKeys[k]; ok {
+		return append(buf, raw...)
+	}
+	return appendString(buf, k, p.escapeHTML, p.escapes == "ascii")
+}
+
+func (p *printer) appendScalar(buf []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case string:
+		return appendString(buf, v, p.escapeHTML, p.escapes == "ascii"), nil
+	case rawString:
+		return append(buf, v.raw...), nil
+	case rawNumber:
+		return append(buf, v.raw...), nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return buf, err
+	}
+	return append(buf, b...), nil
+}
+
+const hex = "0123456789abcdef"
+
+// appendString appends s to buf as a quoted JSON string. Only the characters
+// which must be escaped are escaped, unless escapeHTML is true, in which case
+// "<", ">" and "&" are escaped as well, like encoding/json does. If ascii is
+// true then every non-ASCII character is escaped too.
+func appendString(buf []byte, s string, escapeHTML, ascii bool) []byte {
+	buf = append(buf, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= utf8.RuneSelf {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if ascii {
+				buf = append(buf, s[start:i]...)
+				if r1, r2 := utf16.EncodeRune(r); r1 != utf8.RuneError {
+					buf = appendUnicodeEscape(buf, r1)
+					r = r2
+				}
+				buf = appendUnicodeEscape(buf, r)
+				start = i + size
+			}
+			i += size
+			continue
+		}
+		if c >= 0x20 && c != '"' && c != '\\' && (!escapeHTML || (c != '<' && c != '>' && c != '&')) {
+			i++
+			continue
+		}
+
+		buf = append(buf, s[start:i]...)
+		switch c {
+		case '"', '\\':
+			buf = append(buf, '\\', c)
+		case '\b':
+			buf = append(buf, '\\', 'b')
+		case '\f':
+			buf = append(buf, '\\', 'f')
+		case '\n':
+			buf = append(buf, '\\', 'n')
+		case '\r':
+			buf = append(buf, '\\', 'r')
+		case '\t':
+			buf = append(buf, '\\', 't')
+		default:
+			buf = appendUnicodeEscape(buf, rune(c))
+		}
+		i++
+		start = i
+	}
+	buf = append(buf, s[start:]...)
+
+	return append(buf, '"')
+}
+
+func appendUnicodeEscape(buf []byte, r rune) []byte {
+	return append(buf, '\\', 'u', hex[r>>12&0xf], hex[r>>8&0xf], hex[r>>4&0xf], hex[r&0xf])
+}
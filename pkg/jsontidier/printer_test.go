@@ -0,0 +1,327 @@
+package jsontidier
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxLineWidth(t *testing.T) {
+	orig := `{
+"enum": ["a", "b", "c"],
+"coordinates": [[1, 2], [3, 4]],
+"long": ["this is a long string", "so is this one", "and this one is as well"],
+"nested": {"point": {"x": 1, "y": 2}, "tags": [], "empty": {}},
+"exact": [1234, 5678, 9012, 3456]
+}`
+
+	expect := `{
+    "enum": ["a", "b", "c"],
+    "coordinates": [[1, 2], [3, 4]],
+    "long": [
+        "this is a long string",
+        "so is this one",
+        "and this one is as well"
+    ],
+    "nested": {"point": {"x": 1, "y": 2}, "tags": [], "empty": {}},
+    "exact": [1234, 5678, 9012, 3456]
+}
+`
+
+	compareTidied(t, NewParams{MaxLineWidth: 72}, orig, expect)
+
+	expect = `{
+    "enum": ["a", "b", "c"],
+    "coordinates": [[1, 2], [3, 4]],
+    "long": [
+        "this is a long string",
+        "so is this one",
+        "and this one is as well"
+    ],
+    "nested": {
+        "point": {"x": 1, "y": 2},
+        "tags": [],
+        "empty": {}
+    },
+    "exact": [1234, 5678, 9012, 3456]
+}
+`
+
+	// The "exact" line is 37 characters long, so this is the narrowest width
+	// that keeps it on one line.
+	compareTidied(t, NewParams{MaxLineWidth: 37}, orig, expect)
+}
+
+func TestMaxLineWidthNonASCII(t *testing.T) {
+	orig := `{"names": ["ééééé", "ééééé"], "other": ["ééééé", "ééééééé"]}`
+
+	// The first line is exactly 32 characters long, but it is longer than
+	// that in bytes.
+	expect := `{
+    "names": ["ééééé", "ééééé"],
+    "other": [
+        "ééééé",
+        "ééééééé"
+    ]
+}
+`
+
+	compareTidied(t, NewParams{MaxLineWidth: 32}, orig, expect)
+}
+
+func TestPrinterMatchesMarshalIndent(t *testing.T) {
+	orig := `{"a": [], "b": {}, "c": [1, {"d": "<&>"}, [true, false, null]], "e\u0001": 1.50}`
+
+	jt := NewJSONTidier(NewParams{EscapeHTML: true})
+	tidied, err := jt.TidyString(orig)
+	assert.Nil(t, err, "no error calling TidyString")
+
+	expect, err := json.MarshalIndent(jt, "", "    ")
+	assert.Nil(t, err, "no error calling MarshalIndent")
+	assert.Equal(t, string(expect)+"\n", tidied, "printer output matches MarshalIndent")
+}
+
+func TestFormat(t *testing.T) {
+	orig := `{
+"enum": ["a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l", "m", "n"],
+"geometry": {"coordinates": [[1, 2], [3, 4]], "type": "Polygon"},
+"data": {"short": [1, 2], "points": [{"x": 1, "y": 2}]},
+"short": [1, 2],
+"wrapper": {"x": [1]}
+}`
+
+	expect := `{
+    "enum": ["a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l", "m", "n"],
+    "geometry": {"coordinates": [[1,2],[3,4]], "type": "Polygon"},
+    "data": {
+        "short": [
+            1,
+            2
+        ],
+        "points": [{"x": 1, "y": 2}]
+    },
+    "short": [1, 2],
+    "wrapper": {
+        "x": [
+            1
+        ]
+    }
+}
+`
+
+	compareTidied(
+		t,
+		NewParams{
+			MaxLineWidth: 40,
+			Format: map[string]string{
+				"$..enum":        "inline",
+				"$..coordinates": "compact",
+				"$.geometry":     "inline",
+				"$.data":         "expanded",
+				"$.data.points":  "inline",
+				"$.wrapper.x":    "expanded",
+			},
+		},
+		orig,
+		expect,
+	)
+}
+
+func TestEscapeHTML(t *testing.T) {
+	orig := `{"html": "<a href=\"x\">&amp;</a>", "\u003ckey\u003e": "tab\there\u001fé"}`
+
+	expect := `{
+    "html": "<a href=\"x\">&amp;</a>",
+    "<key>": "tab\there\u001fé"
+}
+`
+
+	compareTidied(t, NewParams{}, orig, expect)
+
+	expect = `{
+    "html": "\u003ca href=\"x\"\u003e\u0026amp;\u003c/a\u003e",
+    "\u003ckey\u003e": "tab\there\u001fé"
+}
+`
+
+	compareTidied(t, NewParams{EscapeHTML: true}, orig, expect)
+}
+
+func TestStringEscapes(t *testing.T) {
+	orig := `{"café": "a\/b\u0009é😀", "plain": "<&>"}`
+
+	expect := `{
+    "café": "a/b\té😀",
+    "plain": "<&>"
+}
+`
+
+	compareTidied(t, NewParams{}, orig, expect)
+	compareTidied(t, NewParams{StringEscapes: "minimal"}, orig, expect)
+
+	expect = `{
+    "café": "a\/b\u0009é😀",
+    "plain": "<&>"
+}
+`
+
+	compareTidied(t, NewParams{StringEscapes: "preserve"}, orig, expect)
+
+	expect = `{
+    "caf\u00e9": "a/b\t\u00e9\ud83d\ude00",
+    "plain": "\u003c\u0026\u003e"
+}
+`
+
+	compareTidied(t, NewParams{StringEscapes: "ascii", EscapeHTML: true}, orig, expect)
+}
+
+func TestMinify(t *testing.T) {
+	orig := `{
+    "b": [3, 1, 2],
+    "a": {"y": "<é>", "x": []},
+    "c": {}
+}`
+
+	expect := `{"a":{"x":[],"y":"<é>"},"b":[1,2,3],"c":{}}
+`
+
+	compareTidied(
+		t,
+		NewParams{
+			Minify:    true,
+			KeyOrder:  map[string][]string{"$": {"a"}, "$.a": {}},
+			ArraySort: []string{"$.b"},
+			Format:    map[string]string{"$.a": "expanded"},
+		},
+		orig,
+		expect,
+	)
+}
+
+func TestAlignValues(t *testing.T) {
+	params := NewParams{
+		AlignValues:           true,
+		AlignValuesMaxPadding: 10,
+		Format:                map[string]string{"$..inline": "inline"},
+	}
+
+	orig := `{"name": "x", "version": "1", "private": true}`
+
+	expect := `{
+    "name":    "x",
+    "version": "1",
+    "private": true
+}
+`
+
+	compareTidied(t, params, orig, expect)
+
+	orig = `{
+"name": "x",
+"scalars": {"a": 1, "longer_key": null},
+"wide": {"a": 1, "a_much_longer_key": 2},
+"inline": {"a": 1, "bb": 2}
+}`
+
+	// The top-level object isn't aligned because it contains objects, and
+	// "wide" isn't aligned because "a" would need too much padding.
+	expect = `{
+    "name": "x",
+    "scalars": {
+        "a":          1,
+        "longer_key": null
+    },
+    "wide": {
+        "a": 1,
+        "a_much_longer_key": 2
+    },
+    "inline": {"a": 1, "bb": 2}
+}
+`
+
+	compareTidied(t, params, orig, expect)
+}
+
+func TestPreserveBlankLines(t *testing.T) {
+	orig := `{
+    "name": "x",
+    "version": "1",
+
+
+    "dependencies": {"b": "2",
+
+        "a": "1"},
+
+    "scripts": {
+
+        "test": "go test"
+    }
+    ,
+
+    "a_first": true
+}`
+
+	expect := `{
+    "a_first": true,
+
+    "dependencies": {
+        "a": "1",
+        "b": "2"
+    },
+    "name": "x",
+
+    "scripts": {"test": "go test"},
+    "version": "1"
+}
+`
+
+	// The blank lines move with the "dependencies", "scripts", "a" and
+	// "a_first" members. The ones before "a_first" and "a" are dropped
+	// because those members are now first. The blank line at the start of
+	// "scripts" is dropped because it's not between two members, so that
+	// object can still be inlined.
+	compareTidied(
+		t,
+		NewParams{
+			PreserveBlankLines: true,
+			MaxLineWidth:       80,
+			SortKeys:           "alphabetical",
+		},
+		orig,
+		expect,
+	)
+}
+
+func TestPreserveBlankLinesWithComments(t *testing.T) {
+	orig := `{
+    "a": 1,
+    // about b
+    "b": 2,
+
+    // about c
+    "c": 3,
+    /* about
+       d */
+
+    "d": 4
+}`
+
+	expect := `{
+    "a": 1,
+    // about b
+    "b": 2,
+
+    // about c
+    "c": 3,
+
+    /* about
+       d */
+    "d": 4
+}
+`
+
+	// Lines which only contain comments are not blank lines.
+	compareTidied(t, NewParams{JSONC: true, PreserveBlankLines: true}, orig, expect)
+}
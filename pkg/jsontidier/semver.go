@@ -0,0 +1,120 @@
+package jsontidier
+
+import (
+	"regexp"
+	"strings"
+)
+
+// This is the regexp suggested at https://semver.org/, minus the named
+// captures.
+var semverRegexp = regexp.MustCompile(
+	`^(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)` +
+		`(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?` +
+		`(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?$`,
+)
+
+type semver struct {
+	major, minor, patch string
+	prerelease          []string
+}
+
+func parseSemver(s string) (semver, bool) {
+	m := semverRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return semver{}, false
+	}
+
+	v := semver{major: m[1], minor: m[2], patch: m[3]}
+	if m[4] != "" {
+		v.prerelease = strings.Split(m[4], ".")
+	}
+
+	return v, true
+}
+
+// semverLess returns a function for sorting an array of strings by semantic
+// version precedence. Any strings which are not valid versions are sorted
+// after all of the valid versions in case-insensitive alphanumeric order. If
+// the array contains anything other than strings it returns nil.
+func semverLess(arr []interface{}) func(a, b interface{}) bool {
+	versions := make(map[string]semver)
+	for _, v := range arr {
+		s, ok := stringValue(v)
+		if !ok {
+			return nil
+		}
+		if sv, ok := parseSemver(s); ok {
+			versions[s] = sv
+		}
+	}
+
+	return func(a, b interface{}) bool {
+		as, _ := stringValue(a)
+		bs, _ := stringValue(b)
+		av, aok := versions[as]
+		bv, bok := versions[bs]
+
+		if aok && bok {
+			return compareSemver(av, bv) < 0
+		} else if aok != bok {
+			return aok
+		}
+		return strings.ToLower(as) < strings.ToLower(bs)
+	}
+}
+
+// compareSemver implements the precedence rules from section 11 of the
+// semver spec. Build metadata is ignored.
+func compareSemver(a, b semver) int {
+	for _, p := range [][2]string{{a.major, b.major}, {a.minor, b.minor}, {a.patch, b.patch}} {
+		if c := compareNumeric(p[0], p[1]); c != 0 {
+			return c
+		}
+	}
+
+	// A version without a pre-release has a higher precedence than one with
+	// a pre-release.
+	if len(a.prerelease) == 0 || len(b.prerelease) == 0 {
+		return len(b.prerelease) - len(a.prerelease)
+	}
+
+	for i := 0; i < len(a.prerelease) && i < len(b.prerelease); i++ {
+		if c := comparePrereleaseIdentifier(a.prerelease[i], b.prerelease[i]); c != 0 {
+			return c
+		}
+	}
+
+	return len(a.prerelease) - len(b.prerelease)
+}
+
+func comparePrereleaseIdentifier(a, b string) int {
+	an := isNumericIdentifier(a)
+	bn := isNumericIdentifier(b)
+	switch {
+	case an && bn:
+		return compareNumeric(a, b)
+	case an:
+		return -1
+	case bn:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func isNumericIdentifier(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// compareNumeric compares two strings of digits without leading zeros, which
+// may be too large to fit in an int.
+func compareNumeric(a, b string) int {
+	if len(a) != len(b) {
+		return len(a) - len(b)
+	}
+	return strings.Compare(a, b)
+}
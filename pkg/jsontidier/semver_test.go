@@ -0,0 +1,43 @@
+package jsontidier
+
+import (
+	"testing"
+)
+
+func TestSemverArraySorting(t *testing.T) {
+	orig := `{
+    "supportedVersions": [ "1.10.0", "1.2.0", "not-a-version", "1.9.3-beta", "1.0.0-rc.1", "1.9.3", "1.0.0-alpha.beta", "1.0.0-alpha.1", "1.0.0-alpha", "1.0.0", "1.0.0-beta.11", "1.0.0-beta.2", "1.0.0-beta", "Also-not" ],
+    "other": [ "1.10.0", "1.2.0" ]
+}`
+
+	expect := `{
+    "supportedVersions": [
+        "1.0.0-alpha",
+        "1.0.0-alpha.1",
+        "1.0.0-alpha.beta",
+        "1.0.0-beta",
+        "1.0.0-beta.2",
+        "1.0.0-beta.11",
+        "1.0.0-rc.1",
+        "1.0.0",
+        "1.2.0",
+        "1.9.3-beta",
+        "1.9.3",
+        "1.10.0",
+        "Also-not",
+        "not-a-version"
+    ],
+    "other": [
+        "1.10.0",
+        "1.2.0"
+    ]
+}
+`
+
+	compareTidied(
+		t,
+		NewParams{ArraySortRules: []ArraySortRule{{Path: "$.supportedVersions", Comparator: "semver"}}},
+		orig,
+		expect,
+	)
+}